@@ -5,13 +5,58 @@ package main
 import (
 	"Project2Demo/FileSystem"
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strings"
+	"time"
 )
 
+// the filesystem the shell is working on
+var fsys *FileSystem.FileSys
+
 func main() {
-	FileSystem.InitializeFileSystem()
+	diskImage := flag.String("disk", "disk.img", "host file that holds the virtual disk")
+	format := flag.Bool("format", false, "wipe the disk image before starting the shell")
+	diskSize := flag.Int64("size", 0, "with -format, size of the disk in bytes (0 for the default)")
+	blockSize := flag.Int("blocksize", 0, "with -format, bytes per block, a power of 2 from 512 to 65536 (0 for the default)")
+	numInodes := flag.Int("inodes", 0, "with -format, how many files and folders the disk can hold (0 for the default)")
+	extents := flag.Bool("extents", false, "with -format, keep track of file blocks as runs of blocks instead of block pointers")
+	journalBlocks := flag.Int("journal", 0, "with -format, blocks set aside for the journal (0 for the default)")
+	flag.Parse()
+
+	var err error
+	if *format {
+		//this works even on an image that can't be mounted any more
+		opts := FileSystem.FormatOptions{
+			TotalSize:     *diskSize,
+			BlockSize:     *blockSize,
+			NumInodes:     *numInodes,
+			JournalBlocks: *journalBlocks,
+		}
+		if *extents {
+			opts.InodeFormat = FileSystem.INODE_EXTENTS
+		}
+		fsys, err = FileSystem.FormatImage(*diskImage, opts)
+		if err != nil {
+			fmt.Println("Error formatting disk:", err)
+			os.Exit(1)
+		}
+	} else {
+		fsys, err = FileSystem.Mount(*diskImage)
+		if err != nil {
+			fmt.Println("Error mounting disk:", err)
+			os.Exit(1)
+		}
+	}
+	defer func() {
+		if err := fsys.Unmount(); err != nil {
+			fmt.Println("Error saving disk:", err)
+		}
+	}()
 	scanner := bufio.NewScanner(os.Stdin)
 
 	for {
@@ -40,12 +85,30 @@ func main() {
 		commandArgs := args[1:]
 
 		switch command {
+		case "format":
+			if err := fsys.Reformat(); err != nil {
+				fmt.Println("Error formatting disk:", err)
+			} else {
+				fmt.Println("Disk formatted.")
+			}
 		case "mv":
 			if len(commandArgs) < 2 {
 				fmt.Println("Usage: mv <source> <destination>")
 			} else {
 				moveFile(commandArgs[0], commandArgs[1])
 			}
+		case "ln":
+			if len(commandArgs) < 2 {
+				fmt.Println("Usage: ln <existing file> <new name>")
+			} else {
+				linkFile(commandArgs[0], commandArgs[1])
+			}
+		case "cd":
+			changeDirectory(commandArgs)
+		case "pwd":
+			printWorkingDirectory()
+		case "ls":
+			listDirectories(commandArgs)
 		case "mkdir":
 			if len(commandArgs) < 1 {
 				fmt.Println("Usage: mkdir <directory name>")
@@ -53,11 +116,7 @@ func main() {
 				makeDirectory(commandArgs[0])
 			}
 		case "cp":
-			if len(commandArgs) < 2 {
-				fmt.Println("Usage: cp <source> <destination>")
-			} else {
-				moveFile(commandArgs[0], commandArgs[1])
-			}
+			copyFiles(commandArgs)
 		case "more":
 			if len(commandArgs) < 1 {
 				fmt.Println("Usage: more <file name>")
@@ -65,11 +124,15 @@ func main() {
 				displayFileContent(commandArgs[0])
 			}
 		case "rm":
+			removeFiles(commandArgs)
+		case "rmdir":
 			if len(commandArgs) < 1 {
-				fmt.Println("Usage: rm <file name>")
+				fmt.Println("Usage: rmdir <directory name>")
 			} else {
-				removeFile(commandArgs[0])
+				removeDirectory(commandArgs[0])
 			}
+		case "fsck":
+			checkDisk(commandArgs)
 		case ">>":
 			if len(commandArgs) < 2 {
 				fmt.Println("Usage: <file name> >> <content>")
@@ -82,152 +145,437 @@ func main() {
 		}
 	}
 }
-func getParentandChildInodes(path string) (parentinode FileSystem.INode, childinode FileSystem.INode, parentinodenum int, childinodenum int) {
-	stringSlice := strings.Split(path, "/")
-	newDirectory := stringSlice[len(stringSlice)-1]
-	stringSlice = stringSlice[:len(stringSlice)-1]
-	var toPath string
-	for _, dir := range stringSlice {
-		if dir != "" {
-			toPath = toPath + "/" + dir
-		}
-	}
-	parentinode, parentinodenum = FileSystem.FindSubdirectories(toPath)
-	childinode, childinodenum = FileSystem.Open(FileSystem.CREATE, newDirectory, parentinode)
-	return parentinode, childinode, parentinodenum, childinodenum
+
+// splitPath cuts path into the folder it is in and its name. The folder keeps its leading / so
+// absolute paths stay absolute, and is "" (the current folder) when path has no / at all
+func splitPath(path string) (parentPath string, fileName string) {
+	if slash := strings.LastIndex(path, "/"); slash >= 0 {
+		return path[:slash+1], path[slash+1:]
+	}
+	return "", path
 }
 
-func moveFile(source, destination string) {
-	moveFile(source, destination)
-	removeFile(destination)
+func getParentandChildInodes(path string, mode int) (parentinode FileSystem.INode, childinode FileSystem.INode, parentinodenum int, childinodenum int, err error) {
+	parentPath, fileName := splitPath(path)
+	parentinode, parentinodenum, err = fsys.Lookup(parentPath)
+	if err != nil {
+		return parentinode, childinode, parentinodenum, childinodenum, err
+	}
+	childFile, err := fsys.Open(mode, fileName, parentinode)
+	if err != nil {
+		return parentinode, childinode, parentinodenum, childinodenum, err
+	}
+	defer childFile.Close()
+	return parentinode, childFile.INode(), parentinodenum, childFile.InodeNum(), nil
 }
 
-func makeDirectory(directoryName string) {
-	parentInode, childInode, parentInodeNum, childInodeNum := getParentandChildInodes(directoryName)
-	if !parentInode.IsValid || !childInode.IsValid {
-		fmt.Println("Error: Failed to get necessary inode information")
+// openFile opens the file at path in the given mode
+func openFile(path string, mode int) (*FileSystem.File, error) {
+	parentPath, fileName := splitPath(path)
+	parentinode, _, err := fsys.Lookup(parentPath)
+	if err != nil {
+		return nil, err
+	}
+	return fsys.Open(mode, fileName, parentinode)
+}
+
+func changeDirectory(args []string) {
+	path := "/" //cd on its own goes back to the root folder
+	if len(args) > 0 {
+		path = args[0]
+	}
+	if err := fsys.Chdir(path); err != nil {
+		fmt.Println("Error changing directory:", err)
+	}
+}
+
+func printWorkingDirectory() {
+	path, err := fsys.Getwd()
+	if err != nil {
+		fmt.Println("Error finding the current directory:", err)
 		return
 	}
+	fmt.Println(path)
+}
 
-	directoryBlock, err := FileSystem.CreateDirectoryFile(parentInodeNum, childInodeNum)
-	if !err.IsValid {
-		fmt.Println("Error creating directory:", err)
+// listDirectories handles ls [-l] [-a] [-R] [path ...], flags can be given together like -la
+func listDirectories(args []string) {
+	longListing, showAll, recursive := false, false, false
+	paths := []string{}
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			paths = append(paths, arg)
+			continue
+		}
+		for _, flagChar := range arg[1:] {
+			switch flagChar {
+			case 'l':
+				longListing = true
+			case 'a':
+				showAll = true
+			case 'R':
+				recursive = true
+			default:
+				fmt.Printf("ls: unknown option -%c\n", flagChar)
+				fmt.Println("Usage: ls [-l] [-a] [-R] [path ...]")
+				return
+			}
+		}
+	}
+	if len(paths) == 0 {
+		paths = append(paths, ".")
+	}
+	for i, path := range paths {
+		inode, inodeNum, err := fsys.Lookup(path)
+		if err != nil {
+			fmt.Println("ls:", err)
+			continue
+		}
+		if !inode.IsDirectory {
+			printListing(path, inodeNum, inode, longListing)
+			continue
+		}
+		if len(paths) > 1 || recursive {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s:\n", path)
+		}
+		listDirectory(path, longListing, showAll, recursive)
+	}
+}
+
+// listDirectory prints one folder's entries sorted by name, then the folders inside it for -R
+func listDirectory(path string, longListing, showAll, recursive bool) {
+	entries, err := fsys.ReadDirectory(path)
+	if err != nil {
+		fmt.Println("ls:", err)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].FileName() < entries[j].FileName() })
+	subFolders := []string{}
+	for _, entry := range entries {
+		name := entry.FileName()
+		isDotEntry := name == "." || name == ".."
+		if isDotEntry && !showAll {
+			continue
+		}
+		inode, err := fsys.GetInode(entry.Inode)
+		if err != nil {
+			fmt.Printf("ls: %s: %s\n", name, err)
+			continue
+		}
+		printListing(name, entry.Inode, inode, longListing)
+		if inode.IsDirectory && !isDotEntry {
+			subFolders = append(subFolders, joinPath(path, name))
+		}
+	}
+	if !recursive {
 		return
 	}
+	for _, subFolder := range subFolders {
+		fmt.Printf("\n%s:\n", subFolder)
+		listDirectory(subFolder, longListing, showAll, recursive)
+	}
+}
 
-	bytesForDirectoryBlock := FileSystem.EncodeToBytes(directoryBlock)
+// printListing prints one line of ls output, -l adds the inode number, type, size and last change
+func printListing(name string, inodeNum int, inode FileSystem.INode, longListing bool) {
+	if !longListing {
+		fmt.Println(name)
+		return
+	}
+	fileType := "file"
+	if inode.IsDirectory {
+		fileType = "dir"
+	}
+	modified := time.Unix(inode.LastModifyTime, 0).Format("2006-01-02 15:04:05")
+	fmt.Printf("%5d  %-4s  %3d  %8d  %s  %s\n", inodeNum, fileType, inode.LinkCount, inode.Size, modified, name)
+}
 
-	FileSystem.Write(&childInode, childInodeNum, bytesForDirectoryBlock)
-	fmt.Printf("Directory '%s' created successfully.\n", directoryName)
+// moveFile renames source to destination, moving it into destination if that is a folder
+func moveFile(source, destination string) {
+	if destinationInode, _, err := fsys.Lookup(destination); err == nil && destinationInode.IsDirectory {
+		if name := baseName(source); name != "" && name != "." && name != ".." {
+			destination = joinPath(destination, name)
+		}
+	}
+	if err := fsys.Rename(source, destination); err != nil {
+		fmt.Println("mv:", err)
+		return
+	}
+	fmt.Printf("Moved %s to %s.\n", source, destination)
 }
 
-func moveFileContent(source, destination string) {
-	_, movingInode, _, movingInodeStatus := getParentandChildInodes(source)
-	if movingInodeStatus == -1 { // Assuming -1 indicates an error or invalid inode.
-		fmt.Printf("Error retrieving source inode for %s or inode is not valid.\n", source)
+// linkFile handles ln, like mv the new name goes inside the destination if that is a folder
+func linkFile(existing, newName string) {
+	if destinationInode, _, err := fsys.Lookup(newName); err == nil && destinationInode.IsDirectory {
+		newName = joinPath(newName, baseName(existing))
+	}
+	if err := fsys.Link(existing, newName); err != nil {
+		fmt.Println("ln:", err)
 		return
 	}
-	if !movingInode.IsValid { // Check if the inode is valid.
-		fmt.Println("Source inode is not valid")
+	fmt.Printf("%s is now another name for %s\n", newName, existing)
+}
+
+func makeDirectory(directoryName string) {
+	if _, _, err := fsys.Mkdir(directoryName); err != nil {
+		fmt.Println("Error creating directory:", err)
 		return
 	}
+	fmt.Printf("Directory '%s' created successfully.\n", directoryName)
+}
 
-	// Read content from the source inode.
-	fileContent := FileSystem.Read(&movingInode)
+// copyFiles handles cp [-r] [-p] <source> <destination>. -r copies folders and everything in them,
+// -p keeps the original creation and modify times instead of stamping the copies with now
+func copyFiles(args []string) {
+	recursive, preserveTimes := false, false
+	paths := []string{}
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			paths = append(paths, arg)
+			continue
+		}
+		for _, flagChar := range arg[1:] {
+			switch flagChar {
+			case 'r', 'R':
+				recursive = true
+			case 'p':
+				preserveTimes = true
+			default:
+				fmt.Printf("cp: unknown option -%c\n", flagChar)
+				paths = nil
+			}
+		}
+	}
+	if len(paths) != 2 {
+		fmt.Println("Usage: cp [-r] [-p] <source> <destination>")
+		return
+	}
+	source, destination := paths[0], paths[1]
 
-	// Retrieve inode information for the destination file, correctly capturing all return values.
-	_, toInode, _, toInodeStatus := getParentandChildInodes(destination)
-	if toInodeStatus == -1 { // Similarrly, -1 for error or invalid inode.
-		fmt.Printf("Error retrieving destination inode for %s or inode is not valid.\n", destination)
+	sourceInode, _, err := fsys.Lookup(source)
+	if err != nil {
+		fmt.Println("cp:", err)
 		return
 	}
-	if !toInode.IsValid {
-		fmt.Println("Destination inode is not valid")
+	//copying onto a folder puts the copy inside it under the same name, like a normal cp
+	if destinationInode, _, err := fsys.Lookup(destination); err == nil && destinationInode.IsDirectory {
+		if name := baseName(source); name != "" && name != "." && name != ".." {
+			destination = joinPath(destination, name)
+		}
+	}
+
+	if sourceInode.IsDirectory {
+		if !recursive {
+			fmt.Printf("cp: %s is a directory (use cp -r)\n", source)
+			return
+		}
+		err = copyDirectory(source, destination, preserveTimes, 0)
+	} else {
+		err = copyFile(source, destination, preserveTimes)
+	}
+	if err != nil {
+		fmt.Println("cp:", err)
 		return
 	}
+	fmt.Printf("Copied %s to %s.\n", source, destination)
+}
+
+// copyFile copies one file's contents into a new inode with its own blocks
+func copyFile(source, destination string, preserveTimes bool) error {
+	sourceFile, err := openFile(source, FileSystem.READ)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+	//opening the destination truncates it, which would wipe out the source if they are the same file
+	if _, destinationNum, err := fsys.Lookup(destination); err == nil && destinationNum == sourceFile.InodeNum() {
+		return fmt.Errorf("%s and %s are the same file", source, destination)
+	}
+	destinationFile, err := openFile(destination, FileSystem.WRITE|FileSystem.CREATE|FileSystem.TRUNC)
+	if err != nil {
+		return err
+	}
+	defer destinationFile.Close()
+	if _, err := io.Copy(destinationFile, sourceFile); err != nil {
+		return err
+	}
+	if preserveTimes {
+		return fsys.SetTimes(destinationFile.InodeNum(), sourceFile.INode().CreateTime, sourceFile.INode().LastModifyTime)
+	}
+	return nil
+}
+
+// copyDirectory copies the folder at source and everything under it to destination. skipInode is
+// the first folder this copy made, so copying a folder into itself doesn't go on forever
+func copyDirectory(source, destination string, preserveTimes bool, skipInode int) error {
+	destinationInode, destinationNum, err := fsys.Lookup(destination)
+	if err != nil {
+		if destinationInode, destinationNum, err = fsys.Mkdir(destination); err != nil {
+			return err
+		}
+	} else if !destinationInode.IsDirectory {
+		return fmt.Errorf("%s: %w", destination, FileSystem.ErrNotDir)
+	}
+	if skipInode == 0 {
+		skipInode = destinationNum
+	}
 
-	// Convert the read content to bytes.
-	inputContent := []byte(fileContent)
+	entries, err := fsys.ReadDirectory(source)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.FileName()
+		if name == "." || name == ".." || entry.Inode == skipInode {
+			continue
+		}
+		childInode, err := fsys.GetInode(entry.Inode)
+		if err != nil {
+			return err
+		}
+		if childInode.IsDirectory {
+			err = copyDirectory(joinPath(source, name), joinPath(destination, name), preserveTimes, skipInode)
+		} else {
+			err = copyFile(joinPath(source, name), joinPath(destination, name), preserveTimes)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if preserveTimes {
+		sourceInode, _, err := fsys.Lookup(source)
+		if err != nil {
+			return err
+		}
+		return fsys.SetTimes(destinationNum, sourceInode.CreateTime, sourceInode.LastModifyTime)
+	}
+	return nil
+}
 
-	// Write the content to the destination inode.
-	FileSystem.Write(&toInode, toInodeStatus, inputContent)
+// baseName is the last part of path, ignoring any slashes on the end
+func baseName(path string) string {
+	_, name := splitPath(strings.TrimRight(path, "/"))
+	return name
+}
 
-	fmt.Printf("Content moved successfully from %s to %s.\n", source, destination)
+// joinPath adds name onto the end of the folder path dir
+func joinPath(dir, name string) string {
+	return strings.TrimSuffix(dir, "/") + "/" + name
 }
 
 func displayFileContent(fileName string) {
-	_, childInode, _, childInodeStatus := getParentandChildInodes(fileName)
-	if childInodeStatus == -1 { // Assuming -1 indicates an error or invalid inode.
-		fmt.Printf("Error retrieving inode for %s or inode is not valid.\n", fileName)
+	file, err := openFile(fileName, FileSystem.READ)
+	if err != nil {
+		fmt.Printf("Error retrieving inode for %s: %s\n", fileName, err)
+		return
+	}
+	defer file.Close()
+
+	// Check if the file has content to read by checking its size.
+	if file.INode().Size == 0 {
+		fmt.Println("Nothing to read in the file.")
 		return
 	}
-	if !childInode.IsValid { // Check if the inode is valid.
-		fmt.Println("Inode for the specified file is not valid")
+	// Stream the content out of the file a block at a time rather than reading it all into memory first.
+	fmt.Println("File content:")
+	if _, err := io.Copy(os.Stdout, file); err != nil {
+		fmt.Printf("\nError reading %s: %s\n", fileName, err)
 		return
 	}
+	fmt.Println()
+}
 
-	// Check if the file has content to read by checking the pointer to the first direct blocke.
-	if childInode.DirectBlock1 == 0 {
-		fmt.Println("Nothing to read in the file.")
-	} else {
-		// Read content from the file.
-		fileContent := FileSystem.Read(&childInode)
-		if fileContent == "" {
-			fmt.Println("File is empty.")
+// removeFiles handles rm [-r] <path> ..., folders are only removed (with everything in them) when -r is given
+func removeFiles(args []string) {
+	recursive := false
+	paths := []string{}
+	for _, arg := range args {
+		if arg == "-r" || arg == "-R" {
+			recursive = true
 		} else {
-			// Output the read content.
-			fmt.Println("File content:")
-			fmt.Println(fileContent)
+			paths = append(paths, arg)
 		}
 	}
-}
-
-func removeFile(fileName string) {
-	// Retrieve inode information for the file to be removed, including the parent and child inodes.
-	parentInode, childInode, _, childInodeNum := getParentandChildInodes(fileName)
-	if !childInode.IsValid {
-		fmt.Println("File does not exist or inode is not valid.")
+	if len(paths) == 0 {
+		fmt.Println("Usage: rm [-r] <file name> ...")
 		return
 	}
+	for _, path := range paths {
+		var err error
+		if recursive {
+			//RemoveAll is happy with paths that aren't there, but rm should say so
+			if _, _, err = fsys.Lookup(path); err == nil {
+				err = fsys.RemoveAll(path)
+			}
+		} else {
+			err = fsys.Remove(path)
+		}
+		if errors.Is(err, FileSystem.ErrIsDir) {
+			fmt.Printf("rm: %s is a directory (use rm -r or rmdir)\n", path)
+			continue
+		}
+		if err != nil {
+			fmt.Printf("Error removing %s: %s\n", path, err)
+			continue
+		}
+		fmt.Printf("%s has been successfully removed!\n", path)
+	}
+}
 
-	// Check if the file has any associated data blocks.
-	if childInode.DirectBlock1 == 0 {
-		fmt.Println("Nothing to remove, the file is empty or unallocated.")
+func removeDirectory(directoryName string) {
+	if err := fsys.Rmdir(directoryName); err != nil {
+		fmt.Printf("Error removing directory %s: %s\n", directoryName, err)
 		return
 	}
+	fmt.Printf("Directory '%s' removed successfully.\n", directoryName)
+}
 
-	// Attempt to unlink (remove) the file using the FileSystem package.
-	err := FileSystem.Unlink(childInodeNum, parentInode)
+// checkDisk handles fsck [-r], the problems are only fixed when -r is given
+func checkDisk(args []string) {
+	repair := false
+	for _, arg := range args {
+		if arg == "-r" {
+			repair = true
+		} else {
+			fmt.Println("Usage: fsck [-r]")
+			return
+		}
+	}
+	report, err := fsys.Check(repair)
+	for _, problem := range report.Problems {
+		fmt.Println(problem)
+	}
 	if err != nil {
-		fmt.Printf("Error removing the file: %s\n", err)
+		fmt.Println("Error checking disk:", err)
 		return
 	}
-
-	fmt.Println("File has been successfully removed!")
+	switch {
+	case report.Clean():
+		fmt.Println("No problems found.")
+	case report.Repaired:
+		fmt.Printf("%d problems found and fixed.\n", len(report.Problems))
+	default:
+		fmt.Printf("%d problems found, run fsck -r to fix them.\n", len(report.Problems))
+	}
 }
 
 func appendToFile(fileName, content string) {
-	// Retrieve the inode for the file to which the content will be appended.
-	_, fileInode, _, fileInodeNum := getParentandChildInodes(fileName)
-	if !fileInode.IsValid {
-		fmt.Println("The specified file does not exist or the inode is not valid.")
+	// Open the file to which the content will be appended, every write on an APPEND handle goes to the end.
+	file, err := openFile(fileName, FileSystem.APPEND|FileSystem.CREATE)
+	if err != nil {
+		fmt.Println("The specified file does not exist or the inode is not valid:", err)
 		return
 	}
+	defer file.Close()
 
-	// Read existing content from the file.
-	existingContent := FileSystem.Read(&fileInode)
-	if existingContent == "" && fileInode.DirectBlock1 != 0 {
-		fmt.Println("Failed to read existing content from the file.")
+	if _, err := file.Write([]byte(content)); err != nil {
+		fmt.Println("Failed to write the file:", err)
 		return
 	}
 
-	// Combine existing content with new content.
-	updatedContent := existingContent + content
-	inputContent := []byte(updatedContent)
-
-	// Write the combined content back to the file.
-	FileSystem.Write(&fileInode, fileInodeNum, inputContent)
-
 	fmt.Println("Content appended successfully.")
 }
 
@@ -238,42 +586,39 @@ Got the osCommand function from Dakotha Proffit, he helped me figure out how it
 func osCommand(command string, args []string) {
 	modifiedArgs := []string{}
 	var nextOutputFile string
-	pathInOutputFile := false
 
 	// Iterate through arguments to handle redirection and check for paths
 	for i, arg := range args {
 		if arg == ">>" {
 			if i+1 < len(args) {
-				nextOutputFile = args[i+1] // Store the next argument after '>>'
-				if strings.Contains(nextOutputFile, "/") {
-					pathInOutputFile = true // Set flag if '/' is found
-				}
+				nextOutputFile = args[i+1] // Store the next argument after '>>', relative to the current folder like every other path
 			}
 			break // Stop processing arguments after '>>'
 		}
 		modifiedArgs = append(modifiedArgs, arg) // Add argument if not part of redirection
 	}
 
+	if len(modifiedArgs) == 0 || nextOutputFile == "" {
+		fmt.Println("Unknown command:", command)
+		return
+	}
+
 	inputFileContent, err := os.ReadFile(modifiedArgs[len(modifiedArgs)-1])
 	if err != nil {
 		fmt.Println("couldn't read in file")
 		fmt.Println(err)
+		return
 	}
 
-	// Output relevant information or take actions based on flags here, if necessary
-	if pathInOutputFile {
-		stringSlice := strings.Split(nextOutputFile, "/")
-		fileName := stringSlice[len(stringSlice)-1]
-		parentinode, _, _, _ := getParentandChildInodes(nextOutputFile)
-		newFileInode, firstInodeNun := FileSystem.Open(FileSystem.CREATE, fileName, parentinode)
-		contentToWrite := []byte(inputFileContent)
-		FileSystem.Write(&newFileInode, firstInodeNun, contentToWrite)
-		fmt.Println("file read in")
-	} else {
-		newFileInode, firstInodeNun := FileSystem.Open(FileSystem.CREATE, nextOutputFile, FileSystem.RootFolder)
-		contentToWrite := []byte(inputFileContent)
-		FileSystem.Write(&newFileInode, firstInodeNun, contentToWrite)
-		fmt.Println("file read in")
+	_, newFileInode, _, newFileInodeNum, err := getParentandChildInodes(nextOutputFile, FileSystem.CREATE)
+	if err != nil {
+		fmt.Println("couldn't create", nextOutputFile, err)
+		return
 	}
-
+	contentToWrite := []byte(inputFileContent)
+	if err := fsys.Write(&newFileInode, newFileInodeNum, contentToWrite); err != nil {
+		fmt.Println("couldn't write", nextOutputFile, err)
+		return
+	}
+	fmt.Println("file read in")
 }
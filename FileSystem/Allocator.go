@@ -0,0 +1,147 @@
+package FileSystem
+
+import "fmt"
+
+// Allocation policies, these decide where allocateNewBlock and allocateExtent look for free blocks
+const (
+	FIRST_FIT = iota //always take the lowest free blocks on the disk
+	NEXT_FIT         //carry on from just after the last blocks handed out, wrapping round at the end
+)
+
+// SetAllocPolicy picks how new blocks are found. FIRST_FIT keeps files packed at the start of the
+// disk, NEXT_FIT doesn't have to scan past all the full blocks every time
+func (fsys *FileSys) SetAllocPolicy(policy int) error {
+	if policy != FIRST_FIT && policy != NEXT_FIT {
+		return fmt.Errorf("allocation policy %d: %w", policy, ErrInvalid)
+	}
+	fsys.allocPolicy = policy
+	return nil
+}
+
+// allocateNewBlock hands out one zeroed block and returns its number
+func (fsys *FileSys) allocateNewBlock() (int, error) {
+	return fsys.allocateExtent(1)
+}
+
+// allocateExtent finds count free blocks in a row, marks them used, zeroes them and returns the
+// number of the first one
+func (fsys *FileSys) allocateExtent(count int) (int, error) {
+	if count <= 0 {
+		return 0, fmt.Errorf("allocate %d blocks: %w", count, ErrInvalid)
+	}
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return 0, err
+	}
+	freeBlockBitmap, err := fsys.freeBlockBitmap(sblock)
+	if err != nil {
+		return 0, err
+	}
+
+	lowest := max(sblock.DataBlockStart, fsys.lowestFree) //nothing below here is free
+	searchFrom := lowest
+	if fsys.allocPolicy == NEXT_FIT && fsys.nextFit > searchFrom && fsys.nextFit < sblock.TotalBlocks {
+		searchFrom = fsys.nextFit
+	}
+	start, found := findFreeRun(freeBlockBitmap, searchFrom, sblock.TotalBlocks, count)
+	if !found && searchFrom > lowest {
+		//next fit wraps round to the start once it runs off the end
+		start, found = findFreeRun(freeBlockBitmap, lowest, sblock.TotalBlocks, count)
+	}
+	if !found {
+		return 0, fmt.Errorf("no run of %d free blocks: %w", count, ErrNoSpace)
+	}
+
+	if err := fsys.claimBlocks(sblock, freeBlockBitmap, start, count); err != nil {
+		return 0, err
+	}
+	return start, nil
+}
+
+// allocateAfter claims up to count free blocks in a row starting right at block start, so a file
+// can grow without moving to a new run. It returns how many it got, which is 0 if start is taken
+func (fsys *FileSys) allocateAfter(start int, count int) (int, error) {
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return 0, err
+	}
+	if start < sblock.DataBlockStart || count <= 0 {
+		return 0, nil
+	}
+	freeBlockBitmap, err := fsys.freeBlockBitmap(sblock)
+	if err != nil {
+		return 0, err
+	}
+	got := 0
+	for got < count && start+got < sblock.TotalBlocks && !freeBlockBitmap.IsSet(start+got) {
+		got++
+	}
+	if got == 0 {
+		return 0, nil
+	}
+	return got, fsys.claimBlocks(sblock, freeBlockBitmap, start, got)
+}
+
+// claimBlocks marks count blocks from start as used, writes the bitmap blocks that changed and
+// zeroes the blocks. If they were the lowest free blocks FIRST_FIT carries on after them next time
+func (fsys *FileSys) claimBlocks(sblock SuperBlock, freeBlockBitmap Bitmap, start int, count int) error {
+	for blockNum := start; blockNum < start+count; blockNum++ {
+		freeBlockBitmap.Set(blockNum)
+	}
+	//only the bitmap blocks the run falls in have changed
+	bitsPerBlock := fsys.blockSize() * 8
+	for bit := start; bit < start+count; bit += bitsPerBlock - bit%bitsPerBlock {
+		if err := fsys.writeBitmapBlock(sblock.FreeBlockStart, freeBlockBitmap, bit); err != nil {
+			return err
+		}
+	}
+	//hand the blocks out zeroed so nothing old shows through a partly written block
+	emptyBlock := make([]byte, fsys.blockSize())
+	for blockNum := start; blockNum < start+count; blockNum++ {
+		if err := fsys.writeDataBlock(blockNum, emptyBlock); err != nil {
+			return err
+		}
+	}
+	fsys.nextFit = start + count
+	if start <= max(sblock.DataBlockStart, fsys.lowestFree) {
+		fsys.lowestFree = max(fsys.lowestFree, start+count)
+	}
+	return nil
+}
+
+// freeBlockBitmap is the allocator's copy of the free block bitmap, so it isn't read in again for
+// every block. claimBlocks keeps it up to date, anything else that changes the bitmap has to call
+// forgetFreeBlocks
+func (fsys *FileSys) freeBlockBitmap(sblock SuperBlock) (Bitmap, error) {
+	if fsys.blockBitmap == nil {
+		bitmap, err := fsys.ReadFreeBlockBitmap(sblock)
+		if err != nil {
+			return nil, err
+		}
+		fsys.blockBitmap = bitmap
+	}
+	return fsys.blockBitmap, nil
+}
+
+// forgetFreeBlocks drops the allocator's copy of the free block bitmap, and with it what it knows
+// about where the free blocks start. The next allocation reads the bitmap again
+func (fsys *FileSys) forgetFreeBlocks() {
+	fsys.blockBitmap = nil
+	fsys.lowestFree = 0
+}
+
+// findFreeRun looks for count clear bits in a row between from and to (not including to)
+func findFreeRun(bitmap Bitmap, from int, to int, count int) (int, bool) {
+	runStart, runLength := from, 0
+	for blockNum := from; blockNum < to; blockNum++ {
+		if bitmap.IsSet(blockNum) {
+			runStart, runLength = blockNum+1, 0
+			continue
+		}
+		runLength++
+		if runLength == count {
+			return runStart, true
+		}
+	}
+	return 0, false
+}
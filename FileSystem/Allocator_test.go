@@ -0,0 +1,86 @@
+package FileSystem
+
+import (
+	"errors"
+	"testing"
+)
+
+// allocateN hands out n single blocks and returns their numbers
+func allocateN(t *testing.T, fsys *FileSys, n int) []int {
+	t.Helper()
+	blockNums := make([]int, n)
+	for i := range blockNums {
+		blockNum, err := fsys.allocateNewBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		blockNums[i] = blockNum
+	}
+	return blockNums
+}
+
+func TestFirstFitReusesFreedBlocks(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	blockNums := allocateN(t, fsys, 4)
+	if err := fsys.freeBlock(blockNums[1]); err != nil {
+		t.Fatal(err)
+	}
+	if got := allocateN(t, fsys, 1)[0]; got != blockNums[1] {
+		t.Fatalf("first fit took block %d after block %d was freed", got, blockNums[1])
+	}
+	if got := allocateN(t, fsys, 1)[0]; got != blockNums[3]+1 {
+		t.Fatalf("first fit took block %d, want %d", got, blockNums[3]+1)
+	}
+}
+
+func TestNextFit(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	if err := fsys.SetAllocPolicy(NEXT_FIT); err != nil {
+		t.Fatal(err)
+	}
+	blockNums := allocateN(t, fsys, 4)
+	if err := fsys.freeBlock(blockNums[1]); err != nil {
+		t.Fatal(err)
+	}
+	if got := allocateN(t, fsys, 1)[0]; got != blockNums[3]+1 {
+		t.Fatalf("next fit took block %d, want %d after the last one handed out", got, blockNums[3]+1)
+	}
+
+	//fill the rest of the disk, then the freed block is the only one left so next fit has to wrap round
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	allocateN(t, fsys, sblock.TotalBlocks-(blockNums[3]+2))
+	if got := allocateN(t, fsys, 1)[0]; got != blockNums[1] {
+		t.Fatalf("next fit took block %d after wrapping round, want %d", got, blockNums[1])
+	}
+	if _, err := fsys.allocateNewBlock(); !errors.Is(err, ErrNoSpace) {
+		t.Fatalf("allocating on a full disk: got %v, want ErrNoSpace", err)
+	}
+}
+
+func TestAllocateExtent(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	blockNums := allocateN(t, fsys, 3)
+	if err := fsys.freeBlock(blockNums[1]); err != nil {
+		t.Fatal(err)
+	}
+	//the one block gap is too small, so the run goes after the blocks in use
+	start, err := fsys.allocateExtent(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start != blockNums[2]+1 {
+		t.Fatalf("a 5 block run started at block %d, want %d", start, blockNums[2]+1)
+	}
+	if got := allocateN(t, fsys, 1)[0]; got != blockNums[1] {
+		t.Fatalf("the gap left before the run wasn't used, got block %d", got)
+	}
+	if _, err := fsys.allocateExtent(3000); !errors.Is(err, ErrNoSpace) {
+		t.Fatalf("allocating more blocks than the disk has: got %v, want ErrNoSpace", err)
+	}
+	if err := fsys.SetAllocPolicy(7); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("unknown allocation policy: got %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,80 @@
+package FileSystem
+
+// Bitmap has one bit for every block (or inode) it tracks, a set bit means it is in use. Bit 0 is
+// the lowest bit of the first byte, so a block's worth of bitmap covers block size*8 blocks
+type Bitmap []byte
+
+// NewBitmap makes an all clear bitmap big enough for numBits bits
+func NewBitmap(numBits int) Bitmap {
+	return make(Bitmap, (numBits+7)/8)
+}
+
+// IsSet reports whether bit is in use
+func (bitmap Bitmap) IsSet(bit int) bool {
+	return bitmap[bit/8]&(1<<(bit%8)) != 0
+}
+
+// Set marks bit as in use
+func (bitmap Bitmap) Set(bit int) {
+	bitmap[bit/8] |= 1 << (bit % 8)
+}
+
+// Clear marks bit as free
+func (bitmap Bitmap) Clear(bit int) {
+	bitmap[bit/8] &^= 1 << (bit % 8)
+}
+
+// bitmapBlocks is how many blocks of blockSize bytes a bitmap of numBits bits takes up on disk
+func bitmapBlocks(numBits int, blockSize int) int {
+	return (numBits + blockSize*8 - 1) / (blockSize * 8)
+}
+
+// readBitmap reads numBits bits of bitmap stored from block startBlock onwards
+func (fsys *FileSys) readBitmap(startBlock int, numBits int) (Bitmap, error) {
+	bitmap := NewBitmap(numBits)
+	blockSize := fsys.blockSize()
+	for blockIndex := 0; blockIndex < bitmapBlocks(numBits, blockSize); blockIndex++ {
+		blockData, err := fsys.readBlock(startBlock + blockIndex)
+		if err != nil {
+			return nil, err
+		}
+		copy(bitmap[blockIndex*blockSize:], blockData)
+	}
+	return bitmap, nil
+}
+
+// writeBitmap stores bitmap from block startBlock onwards, the rest of its last block is left zeroed
+func (fsys *FileSys) writeBitmap(startBlock int, bitmap Bitmap) error {
+	blockSize := fsys.blockSize()
+	for blockIndex := 0; blockIndex*blockSize < len(bitmap); blockIndex++ {
+		end := min((blockIndex+1)*blockSize, len(bitmap))
+		if err := fsys.writeBlock(startBlock+blockIndex, bitmap[blockIndex*blockSize:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBitmapBlocks stores the blocks of the bitmap holding any of bits, each of them once
+func (fsys *FileSys) writeBitmapBlocks(startBlock int, bitmap Bitmap, bits []int) error {
+	bitsPerBlock := fsys.blockSize() * 8
+	written := map[int]bool{}
+	for _, bit := range bits {
+		if written[bit/bitsPerBlock] {
+			continue
+		}
+		written[bit/bitsPerBlock] = true
+		if err := fsys.writeBitmapBlock(startBlock, bitmap, bit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBitmapBlock stores just the block of the bitmap that holds bit, for when only that bit changed
+func (fsys *FileSys) writeBitmapBlock(startBlock int, bitmap Bitmap, bit int) error {
+	blockSize := fsys.blockSize()
+	blockIndex := bit / (blockSize * 8)
+	end := min((blockIndex+1)*blockSize, len(bitmap))
+	return fsys.writeBlock(startBlock+blockIndex, bitmap[blockIndex*blockSize:end])
+}
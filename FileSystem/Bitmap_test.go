@@ -0,0 +1,82 @@
+package FileSystem
+
+import "testing"
+
+func TestBitmapBits(t *testing.T) {
+	bitmap := NewBitmap(20)
+	if len(bitmap) != 3 {
+		t.Fatalf("20 bits take %d bytes", len(bitmap))
+	}
+	bitmap.Set(0)
+	bitmap.Set(9)
+	bitmap.Set(19)
+	if bitmap[0] != 0x01 || bitmap[1] != 0x02 || bitmap[2] != 0x08 {
+		t.Fatalf("bits 0, 9 and 19 set gave % x", []byte(bitmap))
+	}
+	bitmap.Clear(9)
+	for bit := 0; bit < 20; bit++ {
+		if want := bit == 0 || bit == 19; bitmap.IsSet(bit) != want {
+			t.Errorf("bit %d: IsSet is %v, want %v", bit, !want, want)
+		}
+	}
+}
+
+func TestBitmapOnDisk(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	bitsPerBlock := fsys.blockSize() * 8
+	numBits := bitsPerBlock*2 + 5 //runs into a third block
+	bitmap := NewBitmap(numBits)
+	for _, bit := range []int{0, bitsPerBlock, numBits - 1} {
+		bitmap.Set(bit)
+	}
+	start := 3000 - bitmapBlocks(numBits, fsys.blockSize())
+	if err := fsys.writeBitmap(start, bitmap); err != nil {
+		t.Fatal(err)
+	}
+	bitmap.Clear(bitsPerBlock)
+	if err := fsys.writeBitmapBlock(start, bitmap, bitsPerBlock); err != nil {
+		t.Fatal(err)
+	}
+	read, err := fsys.readBitmap(start, numBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(read) != string(bitmap) {
+		t.Fatal("the bitmap read back is different from the one written")
+	}
+}
+
+// countFreeBlocks is how many blocks the free block bitmap has clear
+func countFreeBlocks(t *testing.T, fsys *FileSys) int {
+	t.Helper()
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bitmap, err := fsys.ReadFreeBlockBitmap(sblock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	free := 0
+	for blockNum := 0; blockNum < sblock.TotalBlocks; blockNum++ {
+		if !bitmap.IsSet(blockNum) {
+			free++
+		}
+	}
+	return free
+}
+
+func TestRemoveFreesBlocks(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	before := countFreeBlocks(t, fsys)
+	createFile(t, fsys, "/f", 100*fsys.blockSize()) //needs the indirect block too
+	if used := before - countFreeBlocks(t, fsys); used != 101 {
+		t.Fatalf("a 100 block file took %d blocks, want 101", used)
+	}
+	if err := fsys.Remove("/f"); err != nil {
+		t.Fatal(err)
+	}
+	if after := countFreeBlocks(t, fsys); after != before {
+		t.Fatalf("%d blocks are free after removing the file, want %d", after, before)
+	}
+}
@@ -0,0 +1,127 @@
+package FileSystem
+
+import (
+	"fmt"
+	"os"
+)
+
+// BlockDevice is whatever the filesystem is stored on. Blocks are all BlockSize() bytes
+// and are numbered from 0 to NumBlocks()-1
+type BlockDevice interface {
+	ReadBlock(blockNum int, buf []byte) error
+	WriteBlock(blockNum int, buf []byte) error
+	NumBlocks() int
+	BlockSize() int
+	Flush() error //make sure everything written so far is actually stored
+}
+
+// MemoryDevice keeps every block in RAM, this is what the old global Disk array used to be
+type MemoryDevice struct {
+	data      []byte
+	blockSize int
+}
+
+func NewMemoryDevice(numBlocks int, blockSize int) *MemoryDevice {
+	return &MemoryDevice{data: make([]byte, numBlocks*blockSize), blockSize: blockSize}
+}
+
+func (dev *MemoryDevice) ReadBlock(blockNum int, buf []byte) error {
+	if blockNum < 0 || blockNum >= dev.NumBlocks() {
+		return fmt.Errorf("block number %d out of range", blockNum)
+	}
+	copy(buf, dev.data[blockNum*dev.blockSize:(blockNum+1)*dev.blockSize])
+	return nil
+}
+
+func (dev *MemoryDevice) WriteBlock(blockNum int, buf []byte) error {
+	if blockNum < 0 || blockNum >= dev.NumBlocks() {
+		return fmt.Errorf("block number %d out of range", blockNum)
+	}
+	block := dev.data[blockNum*dev.blockSize : (blockNum+1)*dev.blockSize]
+	clear(block[copy(block, buf):]) //anything shorter than a block gets zero padded
+	return nil
+}
+
+func (dev *MemoryDevice) NumBlocks() int {
+	return len(dev.data) / dev.blockSize
+}
+
+func (dev *MemoryDevice) BlockSize() int {
+	return dev.blockSize
+}
+
+func (dev *MemoryDevice) Flush() error {
+	return nil //nothing to do, RAM is as stored as it gets
+}
+
+// FileDevice reads and writes blocks straight to a host file
+type FileDevice struct {
+	file      *os.File
+	numBlocks int
+	blockSize int
+}
+
+// OpenFileDevice opens (or creates) the image file at path and makes sure it is exactly
+// numBlocks blocks of blockSize bytes long
+func OpenFileDevice(path string, numBlocks int, blockSize int) (*FileDevice, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	diskSize := int64(numBlocks) * int64(blockSize)
+	if info.Size() != diskSize {
+		if info.Size() != 0 {
+			file.Close()
+			return nil, fmt.Errorf("disk image %s is %d bytes, expected %d", path, info.Size(), diskSize)
+		}
+		//a brand new image, grow it to the full disk size (the OS fills it with zeros)
+		if err := file.Truncate(diskSize); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return &FileDevice{file: file, numBlocks: numBlocks, blockSize: blockSize}, nil
+}
+
+func (dev *FileDevice) ReadBlock(blockNum int, buf []byte) error {
+	if blockNum < 0 || blockNum >= dev.numBlocks {
+		return fmt.Errorf("block number %d out of range", blockNum)
+	}
+	block := make([]byte, dev.blockSize)
+	if _, err := dev.file.ReadAt(block, int64(blockNum)*int64(dev.blockSize)); err != nil {
+		return err
+	}
+	copy(buf, block)
+	return nil
+}
+
+func (dev *FileDevice) WriteBlock(blockNum int, buf []byte) error {
+	if blockNum < 0 || blockNum >= dev.numBlocks {
+		return fmt.Errorf("block number %d out of range", blockNum)
+	}
+	block := make([]byte, dev.blockSize) //anything shorter than a block gets zero padded
+	copy(block, buf)
+	_, err := dev.file.WriteAt(block, int64(blockNum)*int64(dev.blockSize))
+	return err
+}
+
+func (dev *FileDevice) NumBlocks() int {
+	return dev.numBlocks
+}
+
+func (dev *FileDevice) BlockSize() int {
+	return dev.blockSize
+}
+
+func (dev *FileDevice) Flush() error {
+	return dev.file.Sync()
+}
+
+func (dev *FileDevice) Close() error {
+	return dev.file.Close()
+}
@@ -0,0 +1,390 @@
+package FileSystem
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// There are two ways an inode can keep track of its blocks (picked when the disk is formatted, see
+// FormatOptions.InodeFormat). Everything else goes through blockForIndex and freeBlocksFrom, which
+// hand the work to the blockMapper for the file's format.
+//
+// With INODE_BLOCKS a file's blocks are found like in the unix filesystem: the first 3 are in
+// DirectBlock1-3, the next ones are listed in the IndirectBlock, after that DoubleIndirect points at a
+// block of indirect blocks and TripleIndirect at a block of double indirect blocks. With 1024 byte
+// blocks that is 3+256+256*256+256*256*256 blocks, way more than the Size field can count, so the
+// real limit on a file is maxFileSize.
+//
+// With INODE_EXTENTS the inode lists runs of blocks instead, see Extent.go
+
+// blockMapper turns a file's block numbers into disk blocks for one inode format
+type blockMapper interface {
+	//mapBlock finds the disk block holding block blockIndex of the file, allocating it if asked
+	mapBlock(file *INode, blockIndex int, allocate bool) (int, error)
+	//reserve makes sure the file has its blocks from firstIndex up to lastIndex, for formats that
+	//do better allocating lots of blocks at once
+	reserve(file *INode, firstIndex, lastIndex int) error
+	//freeFrom gives back every block from firstIndex on
+	freeFrom(file *INode, firstIndex int) error
+	//maxBlocks is how many blocks a file can have at most
+	maxBlocks() int
+	//walkBlocks calls visit with every block the file uses, the indirect blocks and extent leaves
+	//too. A block visit turns down isn't looked inside, and if drop is set it's taken out of the
+	//file as well. changed says the inode needs writing back
+	walkBlocks(file *INode, visit func(blockNum int) bool, drop bool) (changed bool, err error)
+}
+
+// pointerMapper maps blocks with the direct and indirect block pointers
+type pointerMapper struct{ *FileSys }
+
+// mapperFor picks the blockMapper that matches how the file stores its blocks
+func (fsys *FileSys) mapperFor(file *INode) blockMapper {
+	if file.UsesExtents {
+		return extentMapper{fsys}
+	}
+	return pointerMapper{fsys}
+}
+
+// blockForIndex finds the disk block holding block number blockIndex of the file (0 for the first block's worth of bytes and so on).
+// If the file doesn't have that block yet it gets allocated when allocate is true, otherwise 0 comes back.
+// Read, Write and everything else that needs to know where a file's bytes are go through here
+func (fsys *FileSys) blockForIndex(file *INode, blockIndex int, allocate bool) (int, error) {
+	return fsys.mapperFor(file).mapBlock(file, blockIndex, allocate)
+}
+
+// freeBlocksFrom gives back the file's blocks from block number firstIndex onwards (0 for all of
+// them) and clears their pointers. The caller writes the inode back, and has to keep it small
+// enough for one transaction (see shrinkFile)
+func (fsys *FileSys) freeBlocksFrom(file *INode, firstIndex int) error {
+	return fsys.mapperFor(file).freeFrom(file, firstIndex)
+}
+
+// shrinkFile is freeBlocksFrom for files that may be too big to free in one go. It frees from the
+// end a bitmap block's worth of blocks at a time, cutting the size down to match and writing the
+// inode back after each piece, so every piece can be committed on its own. A crash partway leaves
+// a shorter file rather than one pointing at freed blocks
+func (fsys *FileSys) shrinkFile(file *INode, inodeNum int, firstIndex int) error {
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return err
+	}
+	blockSize := fsys.blockSize()
+	piece := blockSize * 8
+	end := max((file.Size+blockSize-1)/blockSize, firstIndex)
+	for {
+		//the first piece also takes anything past the size that a failed write left behind
+		start := max(end-piece, firstIndex)
+		if err := fsys.freeBlocksFrom(file, start); err != nil {
+			return err
+		}
+		file.Size = min(file.Size, start*blockSize)
+		if err := fsys.writeInodeToDisk(file, inodeNum, sblock); err != nil {
+			return err
+		}
+		if start == firstIndex {
+			return nil
+		}
+		if err := fsys.checkpoint(nil); err != nil {
+			return err
+		}
+		end = start
+	}
+}
+
+// maxFileBlocks is how many blocks the file can have
+func (fsys *FileSys) maxFileBlocks(file *INode) int {
+	return fsys.mapperFor(file).maxBlocks()
+}
+
+// maxFileSize is the largest the file can get, either because its blocks run out or because the
+// size wouldn't fit in the inode any more
+func (fsys *FileSys) maxFileSize(file *INode) int64 {
+	return min(int64(fsys.maxFileBlocks(file))*int64(fsys.blockSize()), math.MaxInt32)
+}
+
+// indirectSpan is how many data blocks an indirect block depth levels up can reach
+// (depth 1 is a plain indirect block)
+func (fsys *FileSys) indirectSpan(depth int) int {
+	span := 1
+	for ; depth > 0; depth-- {
+		span *= fsys.pointersPerBlock()
+	}
+	return span
+}
+
+// maxBlocks is how many blocks a file can have before the pointers run out
+func (fsys pointerMapper) maxBlocks() int {
+	return 3 + fsys.indirectSpan(1) + fsys.indirectSpan(2) + fsys.indirectSpan(3)
+}
+
+// reserve gives the file the blocks it is missing from firstIndex up to lastIndex, claiming them
+// as runs so a file written in one go ends up in a row on disk. If the disk fills up the blocks
+// that were found are kept and ErrNoSpace comes back
+func (fsys pointerMapper) reserve(file *INode, firstIndex, lastIndex int) error {
+	for next := firstIndex; next < lastIndex; {
+		gapEnd := next
+		for ; gapEnd < lastIndex; gapEnd++ {
+			blockNum, err := fsys.mapBlock(file, gapEnd, false)
+			if err != nil {
+				return err
+			}
+			if blockNum != 0 {
+				break
+			}
+		}
+		if gapEnd == next {
+			next++ //the file has this one already
+			continue
+		}
+		run := gapEnd - next
+		start, err := fsys.allocateExtent(run)
+		for errors.Is(err, ErrNoSpace) && run > 1 {
+			run /= 2
+			start, err = fsys.allocateExtent(run)
+		}
+		if err != nil {
+			return err
+		}
+		for i := 0; i < run; i++ {
+			blockNum := start + i
+			if _, err := fsys.placeBlock(file, next+i, func() (int, error) { return blockNum, nil }); err != nil {
+				//an indirect block didn't fit, so the rest of the run has nowhere to go
+				for ; i < run; i++ {
+					if freeErr := fsys.freeBlock(start + i); freeErr != nil {
+						return freeErr
+					}
+				}
+				return err
+			}
+		}
+		next += run
+	}
+	return nil
+}
+
+func (fsys pointerMapper) mapBlock(file *INode, blockIndex int, allocate bool) (int, error) {
+	if !allocate {
+		return fsys.placeBlock(file, blockIndex, nil)
+	}
+	return fsys.placeBlock(file, blockIndex, fsys.allocateNewBlock)
+}
+
+// placeBlock is mapBlock with the data block coming from claim, so reserve can hand over blocks it
+// has already claimed. A nil claim leaves missing blocks missing
+func (fsys pointerMapper) placeBlock(file *INode, blockIndex int, claim func() (int, error)) (int, error) {
+	var directBlock *int
+	switch blockIndex {
+	case 0:
+		directBlock = &file.DirectBlock1
+	case 1:
+		directBlock = &file.DirectBlock2
+	case 2:
+		directBlock = &file.DirectBlock3
+	}
+	if directBlock != nil {
+		if *directBlock == 0 && claim != nil {
+			newBlock, err := claim()
+			if err != nil {
+				return 0, err
+			}
+			*directBlock = newBlock
+		}
+		return *directBlock, nil
+	}
+
+	//everything past the third block goes through the indirect blocks, the single indirect one first
+	index := blockIndex - 3
+	for depth, pointer := range []*int{&file.IndirectBlock, &file.DoubleIndirect, &file.TripleIndirect} {
+		span := fsys.indirectSpan(depth + 1)
+		if index < span {
+			return fsys.mapIndirect(pointer, depth+1, index, claim)
+		}
+		index -= span
+	}
+	return 0, fmt.Errorf("block %d is past the largest possible file: %w", blockIndex, ErrFileTooLarge)
+}
+
+// mapIndirect finds entry index under the indirect block *pointer, which is depth levels above
+// the data blocks. Unless claim is nil missing blocks along the way are filled in, indirect ones
+// (including *pointer itself) get allocated and a data block comes from claim. Any indirect block
+// that gets a new pointer is written back
+func (fsys *FileSys) mapIndirect(pointer *int, depth int, index int, claim func() (int, error)) (int, error) {
+	if *pointer == 0 {
+		if claim == nil {
+			return 0, nil
+		}
+		newBlock, err := fsys.allocateNewBlock() //comes back zeroed, so it is an empty indirect block already
+		if err != nil {
+			return 0, err
+		}
+		*pointer = newBlock
+	}
+	indirectBlockVal, err := fsys.readIndirectBlock(*pointer)
+	if err != nil {
+		return 0, err
+	}
+	childSpan := fsys.indirectSpan(depth - 1)
+	slot := index / childSpan
+	before := indirectBlockVal[slot]
+	blockNum := before
+	if depth == 1 {
+		if blockNum == 0 && claim != nil {
+			if blockNum, err = claim(); err != nil {
+				return 0, err
+			}
+			indirectBlockVal[slot] = blockNum
+		}
+	} else if blockNum, err = fsys.mapIndirect(&indirectBlockVal[slot], depth-1, index%childSpan, claim); err != nil {
+		return 0, err
+	}
+	if indirectBlockVal[slot] != before {
+		if err := fsys.writeIndirectBlock(*pointer, indirectBlockVal); err != nil {
+			return 0, err
+		}
+	}
+	return blockNum, nil
+}
+
+// freeFrom clears the block pointers from firstIndex on, indirect blocks go too once nothing in them is left
+func (fsys pointerMapper) freeFrom(file *INode, firstIndex int) error {
+	blockNums := []int{}
+	for index, directBlock := range []*int{&file.DirectBlock1, &file.DirectBlock2, &file.DirectBlock3} {
+		if index >= firstIndex && *directBlock != 0 {
+			blockNums = append(blockNums, *directBlock)
+			*directBlock = 0
+		}
+	}
+	index := firstIndex - 3
+	for depth, pointer := range []*int{&file.IndirectBlock, &file.DoubleIndirect, &file.TripleIndirect} {
+		span := fsys.indirectSpan(depth + 1)
+		if index < span {
+			freed, err := fsys.freeIndirect(pointer, depth+1, max(index, 0))
+			if err != nil {
+				return err
+			}
+			blockNums = append(blockNums, freed...)
+		}
+		index -= span
+	}
+	return fsys.freeBlock(blockNums...)
+}
+
+// freeIndirect clears every pointer under the indirect block *pointer from entry firstIndex on and
+// returns the blocks they pointed at. If that was all of them the indirect block is returned as well
+// and *pointer is cleared, otherwise the trimmed indirect block is written back
+func (fsys *FileSys) freeIndirect(pointer *int, depth int, firstIndex int) ([]int, error) {
+	if *pointer == 0 {
+		return nil, nil
+	}
+	indirectBlockVal, err := fsys.readIndirectBlock(*pointer)
+	if err != nil {
+		return nil, err
+	}
+	childSpan := fsys.indirectSpan(depth - 1)
+	firstSlot := firstIndex / childSpan
+	blockNums := []int{}
+	for slot := firstSlot; slot < len(indirectBlockVal); slot++ {
+		if indirectBlockVal[slot] == 0 {
+			continue
+		}
+		if depth == 1 {
+			blockNums = append(blockNums, indirectBlockVal[slot])
+			indirectBlockVal[slot] = 0
+			continue
+		}
+		childFirst := 0
+		if slot == firstSlot {
+			childFirst = firstIndex % childSpan //only part of the first child goes
+		}
+		freed, err := fsys.freeIndirect(&indirectBlockVal[slot], depth-1, childFirst)
+		if err != nil {
+			return nil, err
+		}
+		blockNums = append(blockNums, freed...)
+	}
+	if firstIndex == 0 {
+		blockNums = append(blockNums, *pointer)
+		*pointer = 0
+		return blockNums, nil
+	}
+	return blockNums, fsys.writeIndirectBlock(*pointer, indirectBlockVal)
+}
+
+func (fsys pointerMapper) walkBlocks(file *INode, visit func(blockNum int) bool, drop bool) (bool, error) {
+	changed := false
+	for _, directBlock := range []*int{&file.DirectBlock1, &file.DirectBlock2, &file.DirectBlock3} {
+		if *directBlock != 0 && !visit(*directBlock) && drop {
+			*directBlock = 0 //that part of the file reads back as zeros now
+			changed = true
+		}
+	}
+	for depth, pointer := range []*int{&file.IndirectBlock, &file.DoubleIndirect, &file.TripleIndirect} {
+		dropped, err := fsys.walkIndirect(pointer, depth+1, visit, drop)
+		if err != nil {
+			return changed, err
+		}
+		changed = changed || dropped
+	}
+	return changed, nil
+}
+
+// walkIndirect visits the indirect block *pointer and everything under it. Pointers that get
+// dropped inside it are written back straight away, it only returns true if *pointer itself went
+func (fsys *FileSys) walkIndirect(pointer *int, depth int, visit func(blockNum int) bool, drop bool) (bool, error) {
+	if *pointer == 0 {
+		return false, nil
+	}
+	if !visit(*pointer) {
+		if drop {
+			*pointer = 0
+		}
+		return drop, nil
+	}
+	indirectBlockVal, err := fsys.readIndirectBlock(*pointer)
+	if err != nil {
+		return false, err
+	}
+	changed := false
+	for slot := range indirectBlockVal {
+		if indirectBlockVal[slot] == 0 {
+			continue
+		}
+		if depth == 1 {
+			if !visit(indirectBlockVal[slot]) && drop {
+				indirectBlockVal[slot] = 0
+				changed = true
+			}
+			continue
+		}
+		dropped, err := fsys.walkIndirect(&indirectBlockVal[slot], depth-1, visit, drop)
+		if err != nil {
+			return false, err
+		}
+		changed = changed || dropped
+	}
+	if changed {
+		return false, fsys.writeIndirectBlock(*pointer, indirectBlockVal)
+	}
+	return false, nil
+}
+
+func (fsys *FileSys) readIndirectBlock(blockNum int) (IndirectBlock, error) {
+	indirectBlockBytes, err := fsys.readBlock(blockNum)
+	if err != nil {
+		return nil, err
+	}
+	var indirectBlockVal IndirectBlock
+	if err := indirectBlockVal.UnmarshalBinary(indirectBlockBytes); err != nil {
+		return nil, fmt.Errorf("indirect block %d: %w", blockNum, err)
+	}
+	return indirectBlockVal, nil
+}
+
+func (fsys *FileSys) writeIndirectBlock(blockNum int, indirectBlockVal IndirectBlock) error {
+	indirectBlockBytes, err := indirectBlockVal.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return fsys.writeBlock(blockNum, indirectBlockBytes)
+}
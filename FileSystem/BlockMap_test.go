@@ -0,0 +1,104 @@
+package FileSystem
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestDoubleIndirectFile(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	before := countFreeBlocks(t, fsys)
+	//3 direct blocks and 256 in the indirect block, the other 100 need the double indirect block
+	const numBlocks = 3 + 256 + 100
+	createFile(t, fsys, "/f", numBlocks*1024)
+	if used := before - countFreeBlocks(t, fsys); used != numBlocks+3 {
+		t.Fatalf("a %d block file took %d blocks, want %d with its 3 indirect blocks", numBlocks, used, numBlocks+3)
+	}
+	file := openFile(t, fsys, "/f")
+	content, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, b := range content {
+		if b != byte(i) {
+			t.Fatalf("byte %d is %d, want %d", i, b, byte(i))
+		}
+	}
+	if err := fsys.Remove("/f"); err != nil {
+		t.Fatal(err)
+	}
+	if after := countFreeBlocks(t, fsys); after != before {
+		t.Fatalf("%d blocks are free after removing the file, want %d", after, before)
+	}
+}
+
+func TestTripleIndirectHole(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	createFile(t, fsys, "/f", 0)
+	before := countFreeBlocks(t, fsys)
+	file := openFile(t, fsys, "/f")
+	defer file.Close()
+	//the first block past everything the direct, indirect and double indirect blocks can reach
+	off := int64(3+256+256*256) * 1024
+	if _, err := file.WriteAt([]byte("x"), off); err != nil {
+		t.Fatal(err)
+	}
+	if used := before - countFreeBlocks(t, fsys); used != 4 {
+		t.Fatalf("one block under the triple indirect block took %d blocks, want 4", used)
+	}
+	buf := make([]byte, 2)
+	if _, err := file.ReadAt(buf, off-1); err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	if buf[0] != 0 || buf[1] != 'x' {
+		t.Fatalf("read % x around the written byte, want 00 78", buf)
+	}
+	if err := file.Truncate(0); err != nil {
+		t.Fatal(err)
+	}
+	if after := countFreeBlocks(t, fsys); after != before {
+		t.Fatalf("%d blocks are free after truncating the file, want %d", after, before)
+	}
+}
+
+func TestWritePastLargestFile(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	createFile(t, fsys, "/f", 100)
+	file := openFile(t, fsys, "/f")
+	if _, err := file.WriteAt([]byte("x"), 1<<31); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("write past the largest file: got %v, want ErrFileTooLarge", err)
+	}
+	file.Close()
+	inode, _, err := fsys.Lookup("/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inode.Size != 100 {
+		t.Fatalf("size is %d after a refused write, want 100", inode.Size)
+	}
+	checkClean(t, fsys)
+}
+
+func TestWriteClaimsOneRun(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	inodeNum := createFile(t, fsys, "/f", 100*1024)
+	inode, err := fsys.getInodeFromDisk(inodeNum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := fsys.blockForIndex(&inode, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for blockIndex := 1; blockIndex < 100; blockIndex++ {
+		blockNum, err := fsys.blockForIndex(&inode, blockIndex, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if blockNum != first+blockIndex {
+			t.Fatalf("block %d of the file is block %d on disk, want %d", blockIndex, blockNum, first+blockIndex)
+		}
+	}
+}
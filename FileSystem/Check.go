@@ -0,0 +1,451 @@
+package FileSystem
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Check goes over the whole disk like fsck, looking for places where the bitmaps, the inodes and the
+// folders don't agree. With repair set it fixes what it finds too:
+//   - inodes that can't be read back are cleared, which takes the entries naming them and their
+//     bits in the bitmaps with them
+//   - a file pointing at a block outside the data blocks, or at a block something else already
+//     uses, loses that block (with extents the whole extent it is in goes and leaves a hole)
+//   - folder entries pointing at free inodes, and second entries for a folder (only files can
+//     have more than one, see Link), are cleared, and
+//     . and .. are pointed back where they belong
+//   - both bitmaps are made to match what the inodes actually use
+//   - each file's LinkCount is set to how many folder entries name it
+//   - valid inodes that no folder leads to go in /lost+found, named #<inode number>
+
+// Kinds of problem Check can find
+const (
+	PROBLEM_INODE_BITMAP    = iota //the inode bitmap doesn't match which inodes are valid
+	PROBLEM_BLOCK_BITMAP           //the free block bitmap doesn't match the blocks that are used
+	PROBLEM_BAD_BLOCK              //a file points at a block that isn't a data block
+	PROBLEM_DUPLICATE_BLOCK        //a block is used twice, by two files or twice by one
+	PROBLEM_BAD_ENTRY              //a folder entry points somewhere it shouldn't
+	PROBLEM_ORPHAN                 //a valid inode no folder leads to
+	PROBLEM_LINK_COUNT             //a file's LinkCount doesn't match how many entries name it
+	PROBLEM_BAD_INODE              //an inode that can't be read back
+)
+
+// Problem is one thing Check found wrong
+type Problem struct {
+	Kind    int //one of the PROBLEM_ constants
+	Inode   int //the inode it's about, 0 if none
+	Block   int //the block it's about, 0 if none
+	Message string
+}
+
+func (problem Problem) String() string {
+	return problem.Message
+}
+
+// Report is everything Check found
+type Report struct {
+	Problems []Problem
+	Repaired bool //the problems were fixed as well as found
+}
+
+// Clean reports whether the disk was fine
+func (report Report) Clean() bool {
+	return len(report.Problems) == 0
+}
+
+// checker keeps track of what Check has worked out about the disk so far
+type checker struct {
+	fsys    *FileSys
+	sblock  SuperBlock
+	repair  bool
+	report  Report
+	inodes  []INode //every inode on the disk by number, inode 0 is never used
+	owner   []int   //the inode using each block, 0 for none
+	reached []bool  //inodes some folder leads to
+	links   []int   //how many folder entries name each inode, orphans count the one lost+found will give them
+	damaged []bool  //folders whose blocks couldn't all be trusted, so their entries aren't read
+}
+
+func (c *checker) problem(kind int, inodeNum int, blockNum int, format string, args ...any) {
+	c.report.Problems = append(c.report.Problems, Problem{
+		Kind:    kind,
+		Inode:   inodeNum,
+		Block:   blockNum,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Check looks the whole disk over and returns what it found wrong, fixing it as well if repair is
+// set. An error means the check itself couldn't carry on, the report has what was found until then
+func (fsys *FileSys) Check(repair bool) (report Report, err error) {
+	fsys.begin()
+	defer func() { err = fsys.commit(err) }()
+
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return Report{}, err
+	}
+	c := &checker{
+		fsys:    fsys,
+		sblock:  sblock,
+		repair:  repair,
+		report:  Report{Repaired: repair},
+		inodes:  make([]INode, sblock.NumInodes),
+		owner:   make([]int, sblock.TotalBlocks),
+		reached: make([]bool, sblock.NumInodes),
+		links:   make([]int, sblock.NumInodes),
+		damaged: make([]bool, sblock.NumInodes),
+	}
+	if err := c.loadInodes(); err != nil {
+		return c.report, err
+	}
+	if root := c.inodes[sblock.RootDirInode]; !root.IsValid || !root.IsDirectory {
+		return c.report, fmt.Errorf("%w: the root folder is gone, there is nothing to check from", ErrCorrupt)
+	}
+
+	if err := c.checkBlocks(); err != nil {
+		return c.report, err
+	}
+	if err := c.walkFolders(sblock.RootDirInode, sblock.RootDirInode); err != nil {
+		return c.report, err
+	}
+	orphans, err := c.findOrphans()
+	if err != nil {
+		return c.report, err
+	}
+	if err := c.checkLinkCounts(); err != nil {
+		return c.report, err
+	}
+	if err := c.checkInodeBitmap(); err != nil {
+		return c.report, err
+	}
+	if err := c.checkBlockBitmap(); err != nil {
+		return c.report, err
+	}
+	if repair {
+		//this goes last, it needs the bitmaps right to make lost+found
+		if err := c.adoptOrphans(orphans); err != nil {
+			return c.report, err
+		}
+		return c.report, fsys.Flush()
+	}
+	return c.report, nil
+}
+
+// loadInodes reads every inode. One that doesn't make sense is left out as if it were free, so
+// whatever names it or is marked used for it gets fixed up by the rest of the check
+func (c *checker) loadInodes() error {
+	for inodeNum := 1; inodeNum < len(c.inodes); inodeNum++ {
+		inode, err := c.fsys.getInodeFromDisk(inodeNum)
+		if errors.Is(err, ErrCorrupt) {
+			c.problem(PROBLEM_BAD_INODE, inodeNum, 0, "%v", err)
+			if c.repair && inodeNum != c.sblock.RootDirInode { //nothing can be checked without the root folder
+				if err := c.fsys.writeInodeToDisk(&INode{}, inodeNum, c.sblock); err != nil {
+					return err
+				}
+				if err := c.fsys.checkpoint(nil); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		c.inodes[inodeNum] = inode
+	}
+	return nil
+}
+
+// checkBlocks works out which inode uses each block, complaining about blocks that aren't data
+// blocks and blocks that are used twice. The first inode to use a block gets to keep it
+func (c *checker) checkBlocks() error {
+	for inodeNum := 1; inodeNum < len(c.inodes); inodeNum++ {
+		file := &c.inodes[inodeNum]
+		if !file.IsValid {
+			continue
+		}
+		mapper := c.fsys.mapperFor(file)
+		problemsBefore := len(c.report.Problems)
+		uses := map[int]bool{}
+		changed, err := mapper.walkBlocks(file, func(blockNum int) bool {
+			if blockNum < c.sblock.DataBlockStart || blockNum >= c.sblock.TotalBlocks {
+				c.problem(PROBLEM_BAD_BLOCK, inodeNum, blockNum, "inode %d points at block %d, which isn't a data block", inodeNum, blockNum)
+				return false
+			}
+			if owner := c.owner[blockNum]; owner != 0 {
+				c.problem(PROBLEM_DUPLICATE_BLOCK, inodeNum, blockNum, "inode %d uses block %d, which inode %d uses already", inodeNum, blockNum, owner)
+				return false
+			}
+			if uses[blockNum] {
+				c.problem(PROBLEM_DUPLICATE_BLOCK, inodeNum, blockNum, "inode %d uses block %d twice", inodeNum, blockNum)
+				return false
+			}
+			uses[blockNum] = true
+			return true
+		}, c.repair)
+		if err != nil {
+			return fmt.Errorf("checking inode %d: %w", inodeNum, err)
+		}
+		if len(c.report.Problems) > problemsBefore && !c.repair {
+			c.damaged[inodeNum] = true
+		}
+		if changed {
+			//some of what was visited has been dropped since, so go round again to see what's left
+			uses = map[int]bool{}
+			if _, err := mapper.walkBlocks(file, func(blockNum int) bool {
+				uses[blockNum] = true
+				return true
+			}, false); err != nil {
+				return fmt.Errorf("checking inode %d: %w", inodeNum, err)
+			}
+			if err := c.fsys.writeInodeToDisk(file, inodeNum, c.sblock); err != nil {
+				return err
+			}
+			if err := c.fsys.checkpoint(nil); err != nil {
+				return err
+			}
+		}
+		for blockNum := range uses {
+			c.owner[blockNum] = inodeNum
+		}
+	}
+	return nil
+}
+
+// walkFolders goes through the folder at dirNum and every folder under it, marking each inode it
+// gets to and checking the entries on the way. parentNum is where the folder's .. should point,
+// or -1 if it doesn't matter
+func (c *checker) walkFolders(dirNum int, parentNum int) error {
+	type folder struct{ dirNum, parentNum int }
+	c.reached[dirNum] = true
+	queue := []folder{{dirNum, parentNum}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if c.damaged[current.dirNum] {
+			continue //its blocks can't be trusted, so neither can its entries
+		}
+		foundDot, foundDotDot := false, false
+		err := c.fsys.scanDirectory(&c.inodes[current.dirNum], func(blockNum int, block DirectoryBlock) (bool, bool) {
+			changed := false
+			for slot, entry := range block {
+				if isFreeEntry(entry) {
+					continue
+				}
+				name := entryName(entry)
+				switch {
+				case name == ".":
+					foundDot = true
+					if entry.Inode != current.dirNum {
+						c.problem(PROBLEM_BAD_ENTRY, current.dirNum, blockNum, ". in folder inode %d points at inode %d", current.dirNum, entry.Inode)
+						block[slot].Inode = current.dirNum
+						changed = true
+					}
+				case name == "..":
+					foundDotDot = true
+					if current.parentNum >= 0 && entry.Inode != current.parentNum {
+						c.problem(PROBLEM_BAD_ENTRY, current.dirNum, blockNum, ".. in folder inode %d points at inode %d instead of %d", current.dirNum, entry.Inode, current.parentNum)
+						block[slot].Inode = current.parentNum
+						changed = true
+					}
+				case entry.Inode <= 0 || entry.Inode >= len(c.inodes) || !c.inodes[entry.Inode].IsValid:
+					c.problem(PROBLEM_BAD_ENTRY, current.dirNum, blockNum, "%s in folder inode %d points at free inode %d", name, current.dirNum, entry.Inode)
+					block[slot] = DirectoryEntry{}
+					changed = true
+				case c.reached[entry.Inode] && c.inodes[entry.Inode].IsDirectory:
+					c.problem(PROBLEM_BAD_ENTRY, current.dirNum, blockNum, "%s in folder inode %d is a second entry for folder inode %d", name, current.dirNum, entry.Inode)
+					block[slot] = DirectoryEntry{}
+					changed = true
+				default:
+					c.links[entry.Inode]++
+					if c.reached[entry.Inode] {
+						continue //another name for a file we've been to already
+					}
+					c.reached[entry.Inode] = true
+					if c.inodes[entry.Inode].IsDirectory {
+						queue = append(queue, folder{entry.Inode, current.dirNum})
+					}
+				}
+			}
+			return changed && c.repair, false
+		})
+		if err != nil {
+			return fmt.Errorf("checking folder inode %d: %w", current.dirNum, err)
+		}
+		if !foundDot || !foundDotDot {
+			c.problem(PROBLEM_BAD_ENTRY, current.dirNum, 0, "folder inode %d is missing its . or .. entry", current.dirNum)
+		}
+		if err := c.fsys.checkpoint(nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findOrphans finds the valid inodes no folder leads to. A lost folder takes what's in it along
+// with it, so only the top of each lost tree comes back
+func (c *checker) findOrphans() ([]int, error) {
+	inLostFolder := map[int]bool{}
+	for inodeNum, inode := range c.inodes {
+		if !inode.IsValid || !inode.IsDirectory || c.reached[inodeNum] || c.damaged[inodeNum] {
+			continue
+		}
+		entries, err := c.fsys.readDirectoryEntries(inode)
+		if err != nil {
+			continue //it's too broken to have anything in it worth finding
+		}
+		for _, entry := range entries {
+			if name := entryName(entry); name != "." && name != ".." {
+				inLostFolder[entry.Inode] = true
+			}
+		}
+	}
+	orphans := []int{}
+	//the second time round picks up lost folders that are only in each other
+	for round := 0; round < 2; round++ {
+		for inodeNum := 1; inodeNum < len(c.inodes); inodeNum++ {
+			if !c.inodes[inodeNum].IsValid || c.reached[inodeNum] || (round == 0 && inLostFolder[inodeNum]) {
+				continue
+			}
+			c.problem(PROBLEM_ORPHAN, inodeNum, 0, "inode %d isn't in any folder", inodeNum)
+			orphans = append(orphans, inodeNum)
+			c.reached[inodeNum] = true
+			c.links[inodeNum]++
+			if c.inodes[inodeNum].IsDirectory {
+				if err := c.walkFolders(inodeNum, -1); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// checkLinkCounts compares each file's LinkCount with the entries walkFolders found for it. A
+// folder can only ever have the one
+func (c *checker) checkLinkCounts() error {
+	for inodeNum := 1; inodeNum < len(c.inodes); inodeNum++ {
+		inode := &c.inodes[inodeNum]
+		if !inode.IsValid {
+			continue
+		}
+		links := c.links[inodeNum]
+		if inode.IsDirectory {
+			links = 1 //the root folder has no entry naming it, the rest have exactly one by now
+		}
+		if inode.LinkCount == links {
+			continue
+		}
+		c.problem(PROBLEM_LINK_COUNT, inodeNum, 0, "inode %d has a link count of %d but %d entries name it", inodeNum, inode.LinkCount, links)
+		if c.repair {
+			inode.LinkCount = links
+			if err := c.fsys.writeInodeToDisk(inode, inodeNum, c.sblock); err != nil {
+				return err
+			}
+			if err := c.fsys.checkpoint(nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkInodeBitmap compares the inode bitmap with which inodes are valid
+func (c *checker) checkInodeBitmap() error {
+	inodeBitmap, err := c.fsys.ReadINodeBitmap(c.sblock)
+	if err != nil {
+		return err
+	}
+	for inodeNum := 1; inodeNum < len(c.inodes); inodeNum++ {
+		valid := c.inodes[inodeNum].IsValid
+		if inodeBitmap.IsSet(inodeNum) == valid {
+			continue
+		}
+		if valid {
+			c.problem(PROBLEM_INODE_BITMAP, inodeNum, 0, "inode %d is in use but marked free", inodeNum)
+			inodeBitmap.Set(inodeNum)
+		} else {
+			c.problem(PROBLEM_INODE_BITMAP, inodeNum, 0, "inode %d is free but marked in use", inodeNum)
+			inodeBitmap.Clear(inodeNum)
+		}
+		if err := c.fixBitmap(c.sblock.InodeBitmapStart, inodeBitmap, inodeNum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkBlockBitmap compares the free block bitmap with the blocks checkBlocks found in use. The
+// blocks before the data blocks are always in use
+func (c *checker) checkBlockBitmap() error {
+	freeBlockBitmap, err := c.fsys.ReadFreeBlockBitmap(c.sblock)
+	if err != nil {
+		return err
+	}
+	for blockNum := 0; blockNum < c.sblock.TotalBlocks; blockNum++ {
+		used := blockNum < c.sblock.DataBlockStart || c.owner[blockNum] != 0
+		if freeBlockBitmap.IsSet(blockNum) == used {
+			continue
+		}
+		if used {
+			c.problem(PROBLEM_BLOCK_BITMAP, c.owner[blockNum], blockNum, "block %d is in use but marked free", blockNum)
+			freeBlockBitmap.Set(blockNum)
+		} else {
+			c.problem(PROBLEM_BLOCK_BITMAP, 0, blockNum, "block %d is marked in use but nothing uses it", blockNum)
+			freeBlockBitmap.Clear(blockNum)
+		}
+		if err := c.fixBitmap(c.sblock.FreeBlockStart, freeBlockBitmap, blockNum); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fixBitmap stores the bitmap block holding bit when repairing. Every bitmap block stands on its
+// own, so a big repair can be committed a block at a time
+func (c *checker) fixBitmap(startBlock int, bitmap Bitmap, bit int) error {
+	if !c.repair {
+		return nil
+	}
+	if err := c.fsys.writeBitmapBlock(startBlock, bitmap, bit); err != nil {
+		return err
+	}
+	c.fsys.forgetFreeBlocks()
+	return c.fsys.checkpoint(nil)
+}
+
+// adoptOrphans puts every orphan in /lost+found, making the folder if it isn't there yet
+func (c *checker) adoptOrphans(orphans []int) error {
+	if len(orphans) == 0 {
+		return nil
+	}
+	lostFound, lostFoundNum, err := c.fsys.Lookup("/lost+found")
+	if errors.Is(err, ErrNotFound) {
+		lostFound, lostFoundNum, err = c.fsys.Mkdir("/lost+found")
+	}
+	if err != nil {
+		return fmt.Errorf("lost+found: %w", err)
+	}
+	if !lostFound.IsDirectory {
+		return fmt.Errorf("lost+found: %w", ErrNotDir)
+	}
+	for _, inodeNum := range orphans {
+		entry, err := newDirectoryEntry(fmt.Sprint("#", inodeNum), inodeNum)
+		if err != nil {
+			return err
+		}
+		if err := c.fsys.addDirectoryEntry(lostFound, entry); err != nil {
+			return fmt.Errorf("lost+found: %w", err)
+		}
+		if c.inodes[inodeNum].IsDirectory {
+			if err := c.fsys.setDirectoryEntry(c.inodes[inodeNum], "..", lostFoundNum); err != nil {
+				return fmt.Errorf("lost+found: %w", err)
+			}
+		}
+		if err := c.fsys.checkpoint(nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
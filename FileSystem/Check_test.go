@@ -0,0 +1,204 @@
+package FileSystem
+
+import "testing"
+
+// checkClean fails the test if Check finds anything wrong
+func checkClean(t *testing.T, fsys *FileSys) {
+	t.Helper()
+	report, err := fsys.Check(false)
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if !report.Clean() {
+		t.Fatalf("check found %v", report.Problems)
+	}
+}
+
+// hasProblem reports whether the report has a problem of kind
+func hasProblem(report Report, kind int) bool {
+	for _, problem := range report.Problems {
+		if problem.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCheckRepairsUndecodableInode(t *testing.T) {
+	for _, format := range []int{INODE_BLOCKS, INODE_EXTENTS} {
+		fsys := newTestDisk(t, 3000, FormatOptions{InodeFormat: format})
+		before := countFreeBlocks(t, fsys)
+		if _, _, err := fsys.Mkdir("/d"); err != nil {
+			t.Fatal(err)
+		}
+		inodeNum := createFile(t, fsys, "/d/f", 300000)
+		createFile(t, fsys, "/g", 3000)
+
+		//give the file a size no inode can have
+		sblock, err := fsys.ReadSuperBlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+		perBlock := sblock.BlockSize / sblock.InodeSize
+		blockNum := sblock.INodeStart + inodeNum/perBlock
+		block, err := fsys.readBlock(blockNum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sizeAt := inodeNum%perBlock*sblock.InodeSize + 8
+		for i := sizeAt; i < sizeAt+8; i++ {
+			block[i] = 0xff
+		}
+		if err := fsys.writeBlock(blockNum, block); err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := fsys.Check(false)
+		if err != nil {
+			t.Fatalf("check stopped at the bad inode: %v", err)
+		}
+		if !hasProblem(report, PROBLEM_BAD_INODE) {
+			t.Fatalf("check didn't report the bad inode, found %v", report.Problems)
+		}
+		if _, err := fsys.Check(true); err != nil {
+			t.Fatalf("repair: %v", err)
+		}
+		checkClean(t, fsys)
+		if _, _, err := fsys.Lookup("/g"); err != nil {
+			t.Fatalf("the other file went too: %v", err)
+		}
+		//the bad inode's blocks have to be free again
+		if err := fsys.Remove("/g"); err != nil {
+			t.Fatal(err)
+		}
+		if err := fsys.Rmdir("/d"); err != nil {
+			t.Fatal(err)
+		}
+		if after := countFreeBlocks(t, fsys); after != before {
+			t.Fatalf("%d blocks free after the repair, want %d", after, before)
+		}
+	}
+}
+
+func TestCheckRepairsBitmaps(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	inodeNum := createFile(t, fsys, "/f", 5000)
+	checkClean(t, fsys)
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inode, err := fsys.getInodeFromDisk(inodeNum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the file's first block looks free and a block nothing uses looks taken
+	blockBitmap, err := fsys.ReadFreeBlockBitmap(sblock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockBitmap.Clear(inode.DirectBlock1)
+	blockBitmap.Set(sblock.TotalBlocks - 1)
+	if err := fsys.writeFreeBlockBitmapToDisk(blockBitmap, sblock); err != nil {
+		t.Fatal(err)
+	}
+	inodeBitmap, err := fsys.ReadINodeBitmap(sblock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inodeBitmap.Clear(inodeNum)
+	if err := fsys.writeInodeBitmapToDisk(inodeBitmap, sblock); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := fsys.Check(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kind := range []int{PROBLEM_BLOCK_BITMAP, PROBLEM_INODE_BITMAP} {
+		if !hasProblem(report, kind) {
+			t.Errorf("check didn't report problem %d, found %v", kind, report.Problems)
+		}
+	}
+	checkClean(t, fsys)
+	//the allocator mustn't hand out the block the repair gave back to the file
+	blockNum, err := fsys.allocateNewBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blockNum == inode.DirectBlock1 {
+		t.Fatalf("block %d was handed out again while the file still uses it", blockNum)
+	}
+}
+
+func TestCheckRepairsEntries(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	if _, _, err := fsys.Mkdir("/d"); err != nil {
+		t.Fatal(err)
+	}
+	inodeNum := createFile(t, fsys, "/d/f", 10)
+	dir, dirNum, err := fsys.Lookup("/d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//.. pointing at the folder itself, and an entry for an inode that isn't in use
+	if err := fsys.setDirectoryEntry(dir, "..", dirNum); err != nil {
+		t.Fatal(err)
+	}
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.freeInode(inodeNum, sblock); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := fsys.Check(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasProblem(report, PROBLEM_BAD_ENTRY) {
+		t.Fatalf("check didn't report the bad entries, found %v", report.Problems)
+	}
+	checkClean(t, fsys)
+	if _, _, err := fsys.Lookup("/d/f"); err == nil {
+		t.Fatal("the entry for the freed inode is still there")
+	}
+	if err := fsys.Chdir("/d"); err != nil {
+		t.Fatal(err)
+	}
+	if wd, err := fsys.Getwd(); err != nil || wd != "/d" {
+		t.Fatalf("getwd in the repaired folder: got %q, %v, want /d", wd, err)
+	}
+}
+
+func TestCheckRepairsLinkCount(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	inodeNum := createFile(t, fsys, "/f", 5000)
+	if err := fsys.Link("/f", "/g"); err != nil {
+		t.Fatal(err)
+	}
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inode, err := fsys.getInodeFromDisk(inodeNum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inode.LinkCount = 7
+	if err := fsys.writeInodeToDisk(&inode, inodeNum, sblock); err != nil {
+		t.Fatal(err)
+	}
+	report, err := fsys.Check(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasProblem(report, PROBLEM_LINK_COUNT) {
+		t.Fatalf("check didn't notice the link count, found %v", report.Problems)
+	}
+	checkClean(t, fsys)
+	if inode, _ = fsys.getInodeFromDisk(inodeNum); inode.LinkCount != 2 {
+		t.Fatalf("link count is %d after the repair, want 2", inode.LinkCount)
+	}
+}
@@ -0,0 +1,326 @@
+package FileSystem
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A folder is stored like any other file, except its blocks are DirectoryBlocks. It starts with one
+// block and grows a block at a time once every slot is taken (into DirectBlock2, DirectBlock3 and then
+// the indirect blocks, or a longer extent). A slot whose name starts with a zero byte is free.
+
+// entryName turns the fixed size name of a directory entry back into a string
+func entryName(entry DirectoryEntry) string {
+	return string(bytes.TrimRight(entry.Name[:], "\x00"))
+}
+
+func isFreeEntry(entry DirectoryEntry) bool {
+	return entry.Name[0] == 0
+}
+
+// newDirectoryEntry checks that name can be stored in a directory entry and builds one
+func newDirectoryEntry(name string, inodeNum int) (DirectoryEntry, error) {
+	entry := DirectoryEntry{Inode: inodeNum}
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return entry, fmt.Errorf("%w: %q", ErrBadName, name)
+	}
+	if len(name) > len(entry.Name) {
+		return entry, fmt.Errorf("%w: %q is longer than %d characters", ErrBadName, name, len(entry.Name))
+	}
+	copy(entry.Name[:], name)
+	return entry, nil
+}
+
+// directoryInodeNum finds a folder's own inode number from its . entry
+func (fsys *FileSys) directoryInodeNum(dir INode) (int, error) {
+	if !dir.IsDirectory || !dir.IsValid {
+		return 0, ErrNotDir
+	}
+	firstBlockNum, err := fsys.blockForIndex(&dir, 0, false)
+	if err != nil {
+		return 0, err
+	}
+	firstBlock, err := fsys.DecodeDirectoryBlock(firstBlockNum)
+	if err != nil {
+		return 0, err
+	}
+	if entryName(firstBlock[0]) != "." {
+		return 0, fmt.Errorf("%w: folder in block %d has no . entry", ErrCorrupt, firstBlockNum)
+	}
+	return firstBlock[0].Inode, nil
+}
+
+// freshDirectory rereads a folder's inode from disk, callers may be holding a copy from before
+// the folder last grew
+func (fsys *FileSys) freshDirectory(dir INode) (INode, int, error) {
+	dirNum, err := fsys.directoryInodeNum(dir)
+	if err != nil {
+		return INode{}, 0, err
+	}
+	dir, err = fsys.getInodeFromDisk(dirNum)
+	if err != nil {
+		return INode{}, 0, err
+	}
+	return dir, dirNum, nil
+}
+
+// scanDirectory calls visit for every block of the folder in order. If visit reports the block as
+// changed it is written back, and scanning stops as soon as visit says so
+func (fsys *FileSys) scanDirectory(dir *INode, visit func(blockNum int, block DirectoryBlock) (changed bool, stop bool)) error {
+	for blockIndex := 0; blockIndex < fsys.maxFileBlocks(dir); blockIndex++ {
+		blockNum, err := fsys.blockForIndex(dir, blockIndex, false)
+		if err != nil {
+			return err
+		}
+		if blockNum == 0 {
+			return nil //folders grow a block at a time, so the first gap is the end
+		}
+		block, err := fsys.DecodeDirectoryBlock(blockNum)
+		if err != nil {
+			return err
+		}
+		changed, stop := visit(blockNum, block)
+		if changed {
+			blockBytes, err := block.MarshalBinary()
+			if err != nil {
+				return err
+			}
+			if err := fsys.writeBlock(blockNum, blockBytes); err != nil {
+				return err
+			}
+		}
+		if stop {
+			return nil
+		}
+	}
+	return nil
+}
+
+// findDirectoryEntry looks name up in every block of the folder
+func (fsys *FileSys) findDirectoryEntry(dir INode, name string) (DirectoryEntry, bool, error) {
+	dir, _, err := fsys.freshDirectory(dir)
+	if err != nil {
+		return DirectoryEntry{}, false, err
+	}
+	var found DirectoryEntry
+	ok := false
+	err = fsys.scanDirectory(&dir, func(blockNum int, block DirectoryBlock) (bool, bool) {
+		for _, entry := range block {
+			if !isFreeEntry(entry) && entryName(entry) == name {
+				found, ok = entry, true
+				return false, true
+			}
+		}
+		return false, false
+	})
+	return found, ok, err
+}
+
+// addDirectoryEntry puts entry in the first free slot of the folder, adding a new block when they are all taken
+func (fsys *FileSys) addDirectoryEntry(dir INode, entry DirectoryEntry) error {
+	dir, dirNum, err := fsys.freshDirectory(dir)
+	if err != nil {
+		return err
+	}
+	added := false
+	numBlocks := 0
+	err = fsys.scanDirectory(&dir, func(blockNum int, block DirectoryBlock) (bool, bool) {
+		numBlocks++
+		for slot := range block {
+			if isFreeEntry(block[slot]) {
+				block[slot] = entry
+				added = true
+				return true, true
+			}
+		}
+		return false, false
+	})
+	if err != nil || added {
+		return err
+	}
+
+	//every slot is taken, so the folder needs another block
+	if numBlocks >= fsys.maxFileBlocks(&dir) {
+		return fmt.Errorf("folder is full: %w", ErrNoSpace)
+	}
+	newBlockNum, err := fsys.blockForIndex(&dir, numBlocks, true)
+	if err != nil {
+		return err
+	}
+	newBlock := fsys.newDirectoryBlock()
+	newBlock[0] = entry
+	newBlockBytes, err := newBlock.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := fsys.writeBlock(newBlockNum, newBlockBytes); err != nil {
+		return err
+	}
+	dir.Size = (numBlocks + 1) * fsys.blockSize()
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return err
+	}
+	return fsys.writeInodeToDisk(&dir, dirNum, sblock)
+}
+
+// removeDirectoryEntry frees the first slot in the folder that matches
+func (fsys *FileSys) removeDirectoryEntry(dir INode, matches func(DirectoryEntry) bool) (DirectoryEntry, error) {
+	dir, _, err := fsys.freshDirectory(dir)
+	if err != nil {
+		return DirectoryEntry{}, err
+	}
+	var removed DirectoryEntry
+	found := false
+	err = fsys.scanDirectory(&dir, func(blockNum int, block DirectoryBlock) (bool, bool) {
+		for slot, entry := range block {
+			name := entryName(entry)
+			if isFreeEntry(entry) || name == "." || name == ".." || !matches(entry) {
+				continue
+			}
+			removed, found = entry, true
+			block[slot] = DirectoryEntry{}
+			return true, true
+		}
+		return false, false
+	})
+	if err != nil {
+		return DirectoryEntry{}, err
+	}
+	if !found {
+		return DirectoryEntry{}, ErrNotFound
+	}
+	return removed, nil
+}
+
+// setDirectoryEntry points the entry called name at inodeNum without moving it, this works on . and .. too
+func (fsys *FileSys) setDirectoryEntry(dir INode, name string, inodeNum int) error {
+	dir, _, err := fsys.freshDirectory(dir)
+	if err != nil {
+		return err
+	}
+	found := false
+	err = fsys.scanDirectory(&dir, func(blockNum int, block DirectoryBlock) (bool, bool) {
+		for slot, entry := range block {
+			if !isFreeEntry(entry) && entryName(entry) == name {
+				block[slot].Inode = inodeNum
+				found = true
+				return true, true
+			}
+		}
+		return false, false
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("entry %s: %w", name, ErrNotFound)
+	}
+	return nil
+}
+
+// isEmptyDirectory reports whether the folder holds nothing but . and ..
+func (fsys *FileSys) isEmptyDirectory(dir INode) (bool, error) {
+	entries, err := fsys.readDirectoryEntries(dir)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if name := entryName(entry); name != "." && name != ".." {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// readDirectoryEntries returns every entry in use in the folder, including . and ..
+func (fsys *FileSys) readDirectoryEntries(dir INode) ([]DirectoryEntry, error) {
+	dir, _, err := fsys.freshDirectory(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := []DirectoryEntry{}
+	err = fsys.scanDirectory(&dir, func(blockNum int, block DirectoryBlock) (bool, bool) {
+		for _, entry := range block {
+			if !isFreeEntry(entry) {
+				entries = append(entries, entry)
+			}
+		}
+		return false, false
+	})
+	return entries, err
+}
+
+// Mkdir makes a new, empty folder at path
+func (fsys *FileSys) Mkdir(path string) (dir INode, dirNum int, err error) {
+	fsys.begin()
+	defer func() { err = fsys.commit(err) }()
+
+	parent, parentNum, name, err := fsys.lookupParent(path)
+	if err != nil {
+		return INode{}, 0, err
+	}
+	file, err := fsys.Open(CREATE|EXCL, name, parent)
+	if err != nil {
+		return INode{}, 0, err
+	}
+	defer file.Close()
+	newNum := file.InodeNum()
+	defer func() {
+		if err == nil {
+			return
+		}
+		//don't leave a half made folder behind
+		if cleanupErr := fsys.Unlink(newNum, parent); cleanupErr != nil {
+			err = fmt.Errorf("removing %s after %v: %w", path, err, cleanupErr)
+		}
+	}()
+	directoryBlock, dir, err := fsys.CreateDirectoryFile(parentNum, newNum)
+	if err != nil {
+		return INode{}, 0, err
+	}
+	blockNum, err := fsys.blockForIndex(&dir, 0, true)
+	if err != nil {
+		return INode{}, 0, err
+	}
+	blockBytes, err := directoryBlock.MarshalBinary()
+	if err != nil {
+		return INode{}, 0, err
+	}
+	if err := fsys.writeBlock(blockNum, blockBytes); err != nil {
+		return INode{}, 0, err
+	}
+	dir.Size = fsys.blockSize()
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return INode{}, 0, err
+	}
+	if err := fsys.writeInodeToDisk(&dir, newNum, sblock); err != nil {
+		return INode{}, 0, err
+	}
+	return dir, newNum, nil
+}
+
+// FileName is the entry's name as a string
+func (entry DirectoryEntry) FileName() string {
+	return entryName(entry)
+}
+
+// ReadDirectory lists every entry of the folder at path, including . and .., in the order they
+// are stored in the folder's blocks
+func (fsys *FileSys) ReadDirectory(path string) ([]DirectoryEntry, error) {
+	dir, _, err := fsys.Lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	if !dir.IsDirectory {
+		return nil, fmt.Errorf("read directory %s: %w", path, ErrNotDir)
+	}
+	return fsys.readDirectoryEntries(dir)
+}
+
+// GetInode reads inode number inodeNum from disk
+func (fsys *FileSys) GetInode(inodeNum int) (INode, error) {
+	return fsys.getInodeFromDisk(inodeNum)
+}
@@ -0,0 +1,95 @@
+package FileSystem
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDirectoryGrowsPastOneBlock(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	//a block holds 32 entries, so this needs the direct blocks and some of the indirect one
+	const numFiles = 200
+	for i := 0; i < numFiles; i++ {
+		createFile(t, fsys, fmt.Sprint("f", i), 10)
+	}
+	for i := 0; i < numFiles; i++ {
+		file := openFile(t, fsys, fmt.Sprint("f", i))
+		file.Close()
+	}
+	entries, err := fsys.readDirectoryEntries(fsys.RootFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != numFiles+2 {
+		t.Fatalf("the root folder lists %d entries, want %d and . and ..", len(entries), numFiles)
+	}
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := fsys.getInodeFromDisk(sblock.RootDirInode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.IndirectBlock == 0 {
+		t.Fatalf("the root folder is %d bytes and never needed its indirect block", root.Size)
+	}
+}
+
+func TestMkdir(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	_, aNum, err := fsys.Mkdir("/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, bNum, err := fsys.Mkdir("/a/b/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, inodeNum, err := fsys.Lookup("/a/b/.."); err != nil || inodeNum != aNum {
+		t.Fatalf("lookup /a/b/..: got inode %d, %v, want %d", inodeNum, err, aNum)
+	}
+	if _, inodeNum, err := fsys.Lookup("/a/b/."); err != nil || inodeNum != bNum {
+		t.Fatalf("lookup /a/b/.: got inode %d, %v, want %d", inodeNum, err, bNum)
+	}
+	if _, _, err := fsys.Mkdir("/a"); !errors.Is(err, ErrExist) {
+		t.Fatalf("mkdir over a folder: got %v, want ErrExist", err)
+	}
+	if _, _, err := fsys.Mkdir("/missing/c"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("mkdir inside a missing folder: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestMkdirOnFullDisk(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	for {
+		if _, err := fsys.allocateNewBlock(); errors.Is(err, ErrNoSpace) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := fsys.ReadINodeBitmap(sblock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := fsys.Mkdir("/d"); !errors.Is(err, ErrNoSpace) {
+		t.Fatalf("mkdir with no free blocks: got %v, want ErrNoSpace", err)
+	}
+	//the entry and inode it made before running out of blocks are gone again
+	if _, _, err := fsys.Lookup("/d"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("lookup the folder that couldn't be made: got %v, want ErrNotFound", err)
+	}
+	after, err := fsys.ReadINodeBitmap(sblock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Fatal("the failed mkdir left an inode in use")
+	}
+}
@@ -0,0 +1,303 @@
+package FileSystem
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Everything on disk is stored little-endian at fixed offsets, so each structure always takes the
+// same number of bytes no matter what is in it. The layouts are:
+//
+//	SuperBlock     magic, layout version, then INodeStart, RootDirInode, FreeBlockStart,
+//	               InodeBitmapStart, DataBlockStart, TotalBlocks, BlockSize, InodeSize,
+//	               NumInodes, InodeFormat, JournalStart and JournalBlocks, all uint32
+//	INode          InodeSize bytes: flags byte (1 valid, 2 directory, 4 uses extents, 8 extents are
+//	               an index), a spare byte, LinkCount uint16, Version uint32,
+//	               Size uint64, DirectBlock1-3 and IndirectBlock uint32, CreateTime and
+//	               LastModifyTime int64, DoubleIndirect and TripleIndirect uint32, then
+//	               INLINE_EXTENTS Extents. The last few fields went into bytes that used to be
+//	               spare and always zero, so older disks still read the same and didn't need a
+//	               new layout version (same for InodeFormat and the journal in the superblock,
+//	               older disks read as having no journal). A valid inode with a LinkCount of 0
+//	               is from before there were links and reads as 1
+//	DirectoryEntry 32 bytes: Inode uint32, the 20 byte Name, 8 spare bytes
+//	DirectoryBlock as many DirectoryEntries as fit in a block
+//	IndirectBlock  one uint32 block number for every 4 bytes of the block
+//	Extent         12 bytes: FileBlock, Start and Length uint32
+//	ExtentBlock    as many Extents as fit in a block
+//	JournalHeader  magic, Count and Checksum uint32, then Count uint32 block numbers
+
+const (
+	SUPERBLOCK_MAGIC     = 0x53464f47 //"GOFS" when read as bytes
+	JOURNAL_MAGIC        = 0x4c4e524a //"JRNL", a journal header without it holds nothing to replay
+	LAYOUT_VERSION       = 2          //bump this whenever one of the layouts above changes
+	SUPERBLOCK_SIZE      = 56
+	DIRECTORY_ENTRY_SIZE = 32
+)
+
+const (
+	inodeFlagValid       = 1 << iota //INode.IsValid
+	inodeFlagDirectory               //INode.IsDirectory
+	inodeFlagExtents                 //INode.UsesExtents
+	inodeFlagExtentIndex             //INode.ExtentIndex
+)
+
+// toUint32 checks that a block or inode number fits in the 4 bytes it gets on disk
+func toUint32(name string, value int) (uint32, error) {
+	if value < 0 || value > math.MaxUint32 {
+		return 0, fmt.Errorf("%s %d doesn't fit in 4 bytes", name, value)
+	}
+	return uint32(value), nil
+}
+
+// putUint32s encodes values one after another from the start of buf
+func putUint32s(buf []byte, names []string, values []int) error {
+	for i, value := range values {
+		encoded, err := toUint32(names[i], value)
+		if err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint32(buf[4*i:], encoded)
+	}
+	return nil
+}
+
+func (sblock SuperBlock) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, SUPERBLOCK_SIZE)
+	binary.LittleEndian.PutUint32(buf[0:], SUPERBLOCK_MAGIC)
+	binary.LittleEndian.PutUint32(buf[4:], LAYOUT_VERSION)
+	err := putUint32s(buf[8:],
+		[]string{"INodeStart", "RootDirInode", "FreeBlockStart", "InodeBitmapStart", "DataBlockStart", "TotalBlocks", "BlockSize", "InodeSize", "NumInodes", "InodeFormat", "JournalStart", "JournalBlocks"},
+		[]int{sblock.INodeStart, sblock.RootDirInode, sblock.FreeBlockStart, sblock.InodeBitmapStart, sblock.DataBlockStart, sblock.TotalBlocks, sblock.BlockSize, sblock.InodeSize, sblock.NumInodes, sblock.InodeFormat, sblock.JournalStart, sblock.JournalBlocks})
+	if err != nil {
+		return nil, fmt.Errorf("encoding superblock: %w", err)
+	}
+	return buf, nil
+}
+
+func (sblock *SuperBlock) UnmarshalBinary(data []byte) error {
+	if len(data) < SUPERBLOCK_SIZE {
+		return fmt.Errorf("%w: superblock is only %d bytes", ErrCorrupt, len(data))
+	}
+	if binary.LittleEndian.Uint32(data[0:]) != SUPERBLOCK_MAGIC {
+		return fmt.Errorf("%w: no superblock found, the disk isn't formatted (or was formatted by an older version)", ErrCorrupt)
+	}
+	if version := binary.LittleEndian.Uint32(data[4:]); version != LAYOUT_VERSION {
+		return fmt.Errorf("%w: disk layout version %d, only version %d is understood", ErrCorrupt, version, LAYOUT_VERSION)
+	}
+	fields := []*int{&sblock.INodeStart, &sblock.RootDirInode, &sblock.FreeBlockStart, &sblock.InodeBitmapStart, &sblock.DataBlockStart, &sblock.TotalBlocks, &sblock.BlockSize, &sblock.InodeSize, &sblock.NumInodes, &sblock.InodeFormat, &sblock.JournalStart, &sblock.JournalBlocks}
+	for i, field := range fields {
+		*field = int(binary.LittleEndian.Uint32(data[8+4*i:]))
+	}
+	return nil
+}
+
+func (inode INode) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, INODE_SIZE)
+	if inode.IsValid {
+		buf[0] |= inodeFlagValid
+	}
+	if inode.IsDirectory {
+		buf[0] |= inodeFlagDirectory
+	}
+	if inode.UsesExtents {
+		buf[0] |= inodeFlagExtents
+	}
+	if inode.ExtentIndex {
+		buf[0] |= inodeFlagExtentIndex
+	}
+	if inode.Size < 0 {
+		return nil, fmt.Errorf("encoding inode: negative size %d", inode.Size)
+	}
+	if inode.LinkCount < 0 || inode.LinkCount > MAX_LINKS {
+		return nil, fmt.Errorf("encoding inode: link count %d out of range", inode.LinkCount)
+	}
+	binary.LittleEndian.PutUint16(buf[2:], uint16(inode.LinkCount))
+	if err := putUint32s(buf[4:], []string{"Version"}, []int{inode.Version}); err != nil {
+		return nil, fmt.Errorf("encoding inode: %w", err)
+	}
+	binary.LittleEndian.PutUint64(buf[8:], uint64(inode.Size))
+	err := putUint32s(buf[16:],
+		[]string{"DirectBlock1", "DirectBlock2", "DirectBlock3", "IndirectBlock"},
+		[]int{inode.DirectBlock1, inode.DirectBlock2, inode.DirectBlock3, inode.IndirectBlock})
+	if err != nil {
+		return nil, fmt.Errorf("encoding inode: %w", err)
+	}
+	binary.LittleEndian.PutUint64(buf[32:], uint64(inode.CreateTime))
+	binary.LittleEndian.PutUint64(buf[40:], uint64(inode.LastModifyTime))
+	err = putUint32s(buf[48:],
+		[]string{"DoubleIndirect", "TripleIndirect"},
+		[]int{inode.DoubleIndirect, inode.TripleIndirect})
+	if err != nil {
+		return nil, fmt.Errorf("encoding inode: %w", err)
+	}
+	for i, extent := range inode.Extents {
+		if err := extent.encode(buf[56+EXTENT_SIZE*i:]); err != nil {
+			return nil, fmt.Errorf("encoding inode: %w", err)
+		}
+	}
+	return buf, nil
+}
+
+func (inode *INode) UnmarshalBinary(data []byte) error {
+	if len(data) < INODE_SIZE {
+		return fmt.Errorf("%w: inode is only %d bytes", ErrCorrupt, len(data))
+	}
+	size := binary.LittleEndian.Uint64(data[8:])
+	if size > math.MaxInt32 {
+		return fmt.Errorf("%w: inode size %d is impossible", ErrCorrupt, size)
+	}
+	*inode = INode{
+		IsValid:        data[0]&inodeFlagValid != 0,
+		IsDirectory:    data[0]&inodeFlagDirectory != 0,
+		UsesExtents:    data[0]&inodeFlagExtents != 0,
+		ExtentIndex:    data[0]&inodeFlagExtentIndex != 0,
+		Version:        int(binary.LittleEndian.Uint32(data[4:])),
+		Size:           int(size),
+		DirectBlock1:   int(binary.LittleEndian.Uint32(data[16:])),
+		DirectBlock2:   int(binary.LittleEndian.Uint32(data[20:])),
+		DirectBlock3:   int(binary.LittleEndian.Uint32(data[24:])),
+		IndirectBlock:  int(binary.LittleEndian.Uint32(data[28:])),
+		CreateTime:     int64(binary.LittleEndian.Uint64(data[32:])),
+		LastModifyTime: int64(binary.LittleEndian.Uint64(data[40:])),
+		DoubleIndirect: int(binary.LittleEndian.Uint32(data[48:])),
+		TripleIndirect: int(binary.LittleEndian.Uint32(data[52:])),
+	}
+	for i := range inode.Extents {
+		inode.Extents[i].decode(data[56+EXTENT_SIZE*i:])
+	}
+	inode.LinkCount = int(binary.LittleEndian.Uint16(data[2:]))
+	if inode.IsValid && inode.LinkCount == 0 {
+		inode.LinkCount = 1 //every file had exactly one name before links were added
+	}
+	return nil
+}
+
+func (entry DirectoryEntry) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, DIRECTORY_ENTRY_SIZE)
+	if err := putUint32s(buf, []string{"Inode"}, []int{entry.Inode}); err != nil {
+		return nil, fmt.Errorf("encoding directory entry: %w", err)
+	}
+	copy(buf[4:], entry.Name[:])
+	return buf, nil
+}
+
+func (entry *DirectoryEntry) UnmarshalBinary(data []byte) error {
+	if len(data) < DIRECTORY_ENTRY_SIZE {
+		return fmt.Errorf("%w: directory entry is only %d bytes", ErrCorrupt, len(data))
+	}
+	entry.Inode = int(binary.LittleEndian.Uint32(data))
+	copy(entry.Name[:], data[4:])
+	return nil
+}
+
+func (block DirectoryBlock) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 0, len(block)*DIRECTORY_ENTRY_SIZE)
+	for _, entry := range block {
+		entryBytes, err := entry.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, entryBytes...)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes as many entries as data holds
+func (block *DirectoryBlock) UnmarshalBinary(data []byte) error {
+	*block = make(DirectoryBlock, len(data)/DIRECTORY_ENTRY_SIZE)
+	for slot := range *block {
+		if err := (*block)[slot].UnmarshalBinary(data[slot*DIRECTORY_ENTRY_SIZE:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (block IndirectBlock) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, 4*len(block))
+	for i, blockNum := range block {
+		encoded, err := toUint32("block number", blockNum)
+		if err != nil {
+			return nil, fmt.Errorf("encoding indirect block: %w", err)
+		}
+		binary.LittleEndian.PutUint32(buf[4*i:], encoded)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes as many block numbers as data holds
+func (block *IndirectBlock) UnmarshalBinary(data []byte) error {
+	*block = make(IndirectBlock, len(data)/4)
+	for i := range *block {
+		(*block)[i] = int(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+	return nil
+}
+
+// encode puts the extent in the first EXTENT_SIZE bytes of buf
+func (extent Extent) encode(buf []byte) error {
+	return putUint32s(buf,
+		[]string{"FileBlock", "Start", "Length"},
+		[]int{extent.FileBlock, extent.Start, extent.Length})
+}
+
+func (extent *Extent) decode(data []byte) {
+	extent.FileBlock = int(binary.LittleEndian.Uint32(data[0:]))
+	extent.Start = int(binary.LittleEndian.Uint32(data[4:]))
+	extent.Length = int(binary.LittleEndian.Uint32(data[8:]))
+}
+
+func (block ExtentBlock) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, EXTENT_SIZE*len(block))
+	for i, extent := range block {
+		if err := extent.encode(buf[EXTENT_SIZE*i:]); err != nil {
+			return nil, fmt.Errorf("encoding extent block: %w", err)
+		}
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes as many extents as data holds
+func (block *ExtentBlock) UnmarshalBinary(data []byte) error {
+	*block = make(ExtentBlock, len(data)/EXTENT_SIZE)
+	for i := range *block {
+		(*block)[i].decode(data[EXTENT_SIZE*i:])
+	}
+	return nil
+}
+
+func (header JournalHeader) MarshalBinary() ([]byte, error) {
+	buf := make([]byte, JOURNAL_HEADER_SIZE+4*len(header.Blocks))
+	binary.LittleEndian.PutUint32(buf[0:], JOURNAL_MAGIC)
+	binary.LittleEndian.PutUint32(buf[4:], uint32(len(header.Blocks)))
+	binary.LittleEndian.PutUint32(buf[8:], header.Checksum)
+	for i, blockNum := range header.Blocks {
+		encoded, err := toUint32("block number", blockNum)
+		if err != nil {
+			return nil, fmt.Errorf("encoding journal header: %w", err)
+		}
+		binary.LittleEndian.PutUint32(buf[JOURNAL_HEADER_SIZE+4*i:], encoded)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary leaves the header empty if data doesn't start with JOURNAL_MAGIC, that is how
+// a journal with nothing in it looks
+func (header *JournalHeader) UnmarshalBinary(data []byte) error {
+	*header = JournalHeader{}
+	if len(data) < JOURNAL_HEADER_SIZE || binary.LittleEndian.Uint32(data[0:]) != JOURNAL_MAGIC {
+		return nil
+	}
+	count := int(binary.LittleEndian.Uint32(data[4:]))
+	if count > (len(data)-JOURNAL_HEADER_SIZE)/4 {
+		return fmt.Errorf("%w: journal header lists %d blocks, only %d fit", ErrCorrupt, count, (len(data)-JOURNAL_HEADER_SIZE)/4)
+	}
+	header.Checksum = binary.LittleEndian.Uint32(data[8:])
+	header.Blocks = make([]int, count)
+	for i := range header.Blocks {
+		header.Blocks[i] = int(binary.LittleEndian.Uint32(data[JOURNAL_HEADER_SIZE+4*i:]))
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package FileSystem
+
+import (
+	"encoding"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEncodingRoundTrip(t *testing.T) {
+	var name [20]byte
+	copy(name[:], "a name")
+	directory := make(DirectoryBlock, 32)
+	directory[0], directory[2] = DirectoryEntry{Inode: 1, Name: name}, DirectoryEntry{Inode: 2}
+	indirect := make(IndirectBlock, 256)
+	for i := range indirect {
+		indirect[i] = 1000 + i
+	}
+	tests := []struct {
+		name    string
+		value   encoding.BinaryMarshaler
+		decoded encoding.BinaryUnmarshaler
+		size    int
+	}{
+		{"superblock", SuperBlock{INodeStart: 20, RootDirInode: 1, FreeBlockStart: 2, InodeBitmapStart: 1, DataBlockStart: 150, TotalBlocks: 3000, BlockSize: 1024, InodeSize: INODE_SIZE, NumInodes: 64}, &SuperBlock{}, SUPERBLOCK_SIZE},
+		{"inode", INode{IsValid: true, IsDirectory: true, LinkCount: 2, Size: 5000, DirectBlock1: 200, DirectBlock2: 201, DirectBlock3: 202, IndirectBlock: 203, CreateTime: -5, LastModifyTime: 1 << 40}, &INode{}, INODE_SIZE},
+		{"directory entry", DirectoryEntry{Inode: 7, Name: name}, &DirectoryEntry{}, DIRECTORY_ENTRY_SIZE},
+		{"directory block", directory, &DirectoryBlock{}, 1024},
+		{"indirect block", indirect, &IndirectBlock{}, 1024},
+	}
+	for _, test := range tests {
+		encoded, err := test.value.MarshalBinary()
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(encoded) != test.size {
+			t.Errorf("%s is %d bytes encoded, want %d", test.name, len(encoded), test.size)
+		}
+		if err := test.decoded.UnmarshalBinary(encoded); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got := reflect.ValueOf(test.decoded).Elem().Interface(); !reflect.DeepEqual(got, test.value) {
+			t.Errorf("%s: decoded %+v, want %+v", test.name, got, test.value)
+		}
+	}
+}
+
+func TestEncodingRejects(t *testing.T) {
+	if _, err := (INode{DirectBlock1: -1}).MarshalBinary(); err == nil {
+		t.Error("a negative block number was encoded")
+	}
+	var sblock SuperBlock
+	if err := sblock.UnmarshalBinary(make([]byte, SUPERBLOCK_SIZE)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("a superblock of zeros: got %v, want ErrCorrupt", err)
+	}
+	var inode INode
+	if err := inode.UnmarshalBinary(make([]byte, 10)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("a short inode: got %v, want ErrCorrupt", err)
+	}
+}
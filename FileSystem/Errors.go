@@ -0,0 +1,21 @@
+package FileSystem
+
+import "errors"
+
+// Everything the filesystem returns wraps one of these, so callers can check them with errors.Is
+var (
+	ErrNotFound     = errors.New("no such file or directory")
+	ErrNoSpace      = errors.New("no space left on disk")
+	ErrNoInodes     = errors.New("no free inodes left")
+	ErrNotDir       = errors.New("not a directory")
+	ErrIsDir        = errors.New("is a directory")
+	ErrCorrupt      = errors.New("filesystem is corrupt")
+	ErrExist        = errors.New("file already exists")
+	ErrBadMode      = errors.New("file not opened for that")
+	ErrClosed       = errors.New("file already closed")
+	ErrBadName      = errors.New("invalid file name")
+	ErrNotEmpty     = errors.New("directory not empty")
+	ErrInvalid      = errors.New("invalid argument")
+	ErrFileTooLarge = errors.New("file too large")
+	ErrJournalFull  = errors.New("operation too big for the journal")
+)
@@ -0,0 +1,351 @@
+package FileSystem
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// With the INODE_EXTENTS format a file's blocks are kept as extents, runs of blocks that sit next to
+// each other on disk, so a big file written in one go needs a handful of them instead of a pointer
+// for every block. The extents make a small tree: the inode holds up to INLINE_EXTENTS of them, and
+// once a file needs more the inode's entries point at leaf blocks full of extents instead (that's
+// what ExtentIndex means). The extents are kept in the order of the file's blocks, and blocks none
+// of them cover read back as zeros like never written pointer blocks do. That's how holes work: a
+// write far past the end only gets blocks for what it writes, not for the gap before it.
+
+const (
+	INLINE_EXTENTS = 6  //extents that fit in the spare bytes at the end of an inode
+	EXTENT_SIZE    = 12 //FileBlock, Start and Length, a uint32 each
+)
+
+// Extent is Length blocks in a row on disk starting at block Start, they hold the file's blocks
+// from FileBlock on. In the inode of an indexed file Start is a leaf block of extents instead and
+// Length is how many of the file's blocks that leaf covers. Entries with a Length of 0 are unused
+type Extent struct {
+	FileBlock int
+	Start     int
+	Length    int
+}
+
+type ExtentBlock []Extent //a leaf of the extent tree, as many extents as fit in a block
+
+// extentMapper maps blocks through the inode's extents
+type extentMapper struct{ *FileSys }
+
+// extentsPerBlock is how many extents fit in a leaf block
+func (fsys *FileSys) extentsPerBlock() int {
+	return fsys.blockSize() / EXTENT_SIZE
+}
+
+// maxBlocks doesn't depend on the extents, one extent can be as long as the disk. What stops a
+// file is either the size limit or running out of extents because the disk is too fragmented
+func (fsys extentMapper) maxBlocks() int {
+	return math.MaxInt32/fsys.blockSize() + 1
+}
+
+// findExtent finds the entry covering the file's block blockIndex, nil if none does
+func findExtent(extents []Extent, blockIndex int) *Extent {
+	for i := range extents {
+		extent := &extents[i]
+		if extent.Length > 0 && blockIndex >= extent.FileBlock && blockIndex < extent.FileBlock+extent.Length {
+			return extent
+		}
+	}
+	return nil
+}
+
+func (fsys extentMapper) mapBlock(file *INode, blockIndex int, allocate bool) (int, error) {
+	if blockIndex >= fsys.maxBlocks() {
+		return 0, fmt.Errorf("block %d is past the largest possible file: %w", blockIndex, ErrFileTooLarge)
+	}
+	if allocate {
+		if err := fsys.reserve(file, blockIndex, blockIndex+1); err != nil {
+			return 0, err
+		}
+	}
+	extents := file.Extents[:]
+	if file.ExtentIndex {
+		leaf := findExtent(extents, blockIndex)
+		if leaf == nil {
+			return 0, nil
+		}
+		leafBlock, err := fsys.readExtentBlock(leaf.Start)
+		if err != nil {
+			return 0, err
+		}
+		extents = leafBlock
+	}
+	if extent := findExtent(extents, blockIndex); extent != nil {
+		return extent.Start + blockIndex - extent.FileBlock, nil
+	}
+	return 0, nil
+}
+
+// reserve gives the file blocks firstIndex up to lastIndex, leaving alone the ones it has already.
+// A gap right after an extent stretches it if the blocks after it on disk are free, otherwise the
+// rest goes in as few new runs as the allocator can find. Blocks before firstIndex that the file
+// doesn't have stay a hole. If the disk fills up the blocks that were found are kept and
+// ErrNoSpace comes back
+func (fsys extentMapper) reserve(file *INode, firstIndex, lastIndex int) error {
+	extents, err := fsys.loadExtents(file)
+	if err != nil {
+		return err
+	}
+	claimed := []Extent{} //in case they have to be given back
+	var allocErr error
+	for next := firstIndex; next < lastIndex && allocErr == nil; {
+		if extent := findExtent(extents, next); extent != nil {
+			next = extent.FileBlock + extent.Length //the file has these already
+			continue
+		}
+		gapEnd := lastIndex
+		for _, extent := range extents {
+			if extent.FileBlock > next && extent.FileBlock < gapEnd {
+				gapEnd = extent.FileBlock
+			}
+		}
+		var got []Extent
+		extents, got, allocErr = fsys.fillGap(extents, next, gapEnd)
+		claimed = append(claimed, got...)
+		next = gapEnd
+	}
+	if len(claimed) == 0 {
+		return allocErr
+	}
+	sort.Slice(extents, func(i, j int) bool { return extents[i].FileBlock < extents[j].FileBlock })
+	if err := fsys.storeExtents(file, extents); err != nil {
+		for _, extent := range claimed {
+			fsys.freeRun(extent) //the extents on disk don't know about these, so don't leak them
+		}
+		return err
+	}
+	return allocErr
+}
+
+// fillGap allocates the file's blocks from first up to end, which none of the extents cover. It
+// returns the extents with the new blocks added and the runs that were claimed for them
+func (fsys extentMapper) fillGap(extents []Extent, first, end int) ([]Extent, []Extent, error) {
+	claimed := []Extent{}
+	for needed := end - first; needed > 0; {
+		fileBlock := end - needed
+		if before := findExtent(extents, fileBlock-1); before != nil {
+			got, err := fsys.allocateAfter(before.Start+before.Length, needed)
+			if err != nil {
+				return extents, claimed, err
+			}
+			if got > 0 {
+				claimed = append(claimed, Extent{Start: before.Start + before.Length, Length: got})
+				before.Length += got
+				needed -= got
+				continue
+			}
+		}
+		//no room after the run before, so start a new one, as long as the disk has space for
+		run := needed
+		start, err := fsys.allocateExtent(run)
+		for errors.Is(err, ErrNoSpace) && run > 1 {
+			run /= 2
+			start, err = fsys.allocateExtent(run)
+		}
+		if err != nil {
+			return extents, claimed, err
+		}
+		newExtent := Extent{FileBlock: fileBlock, Start: start, Length: run}
+		extents = append(extents, newExtent)
+		claimed = append(claimed, newExtent)
+		needed -= run
+	}
+	return extents, claimed, nil
+}
+
+// freeFrom gives back every block from firstIndex on, shortening the extent that straddles it
+func (fsys extentMapper) freeFrom(file *INode, firstIndex int) error {
+	extents, err := fsys.loadExtents(file)
+	if err != nil {
+		return err
+	}
+	kept := []Extent{}
+	freed := []Extent{}
+	for _, extent := range extents {
+		if extent.FileBlock >= firstIndex {
+			freed = append(freed, extent)
+			continue
+		}
+		if keep := firstIndex - extent.FileBlock; keep < extent.Length {
+			freed = append(freed, Extent{Start: extent.Start + keep, Length: extent.Length - keep})
+			extent.Length = keep
+		}
+		kept = append(kept, extent)
+	}
+	if err := fsys.storeExtents(file, kept); err != nil {
+		return err
+	}
+	for _, extent := range freed {
+		if err := fsys.freeRun(extent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkBlocks visits the leaf blocks and the blocks of every extent. Dropping a block takes out the
+// extent (or the leaf) it is in, that part of the file is a hole and reads back as zeros after
+func (fsys extentMapper) walkBlocks(file *INode, visit func(blockNum int) bool, drop bool) (bool, error) {
+	changed := false
+	for i, entry := range file.Extents {
+		if entry.Length == 0 {
+			continue
+		}
+		if !file.ExtentIndex {
+			if !visitRun(entry, visit) && drop {
+				file.Extents[i] = Extent{}
+				changed = true
+			}
+			continue
+		}
+		if !visit(entry.Start) {
+			if drop {
+				file.Extents[i] = Extent{}
+				changed = true
+			}
+			continue //don't look inside a leaf that can't be trusted
+		}
+		leafBlock, err := fsys.readExtentBlock(entry.Start)
+		if err != nil {
+			return changed, err
+		}
+		leafChanged := false
+		for j, extent := range leafBlock {
+			if extent.Length > 0 && !visitRun(extent, visit) && drop {
+				leafBlock[j] = Extent{}
+				leafChanged = true
+			}
+		}
+		if leafChanged {
+			//the inode itself is the same, but what was visited in the extents that went has to be walked again
+			changed = true
+			if err := fsys.writeExtentBlock(entry.Start, leafBlock); err != nil {
+				return changed, err
+			}
+		}
+	}
+	return changed, nil
+}
+
+// visitRun visits the blocks of an extent until one is turned down
+func visitRun(extent Extent, visit func(blockNum int) bool) bool {
+	for blockNum := extent.Start; blockNum < extent.Start+extent.Length; blockNum++ {
+		if !visit(blockNum) {
+			return false
+		}
+	}
+	return true
+}
+
+// freeRun gives back the blocks of one extent
+func (fsys *FileSys) freeRun(extent Extent) error {
+	blockNums := make([]int, extent.Length)
+	for i := range blockNums {
+		blockNums[i] = extent.Start + i
+	}
+	return fsys.freeBlock(blockNums...)
+}
+
+// loadExtents returns every extent of the file in order, reading the leaf blocks if there are any
+func (fsys *FileSys) loadExtents(file *INode) ([]Extent, error) {
+	extents := []Extent{}
+	for _, entry := range file.Extents {
+		if entry.Length == 0 {
+			continue
+		}
+		if !file.ExtentIndex {
+			extents = append(extents, entry)
+			continue
+		}
+		leafBlock, err := fsys.readExtentBlock(entry.Start)
+		if err != nil {
+			return nil, err
+		}
+		for _, extent := range leafBlock {
+			if extent.Length > 0 {
+				extents = append(extents, extent)
+			}
+		}
+	}
+	return extents, nil
+}
+
+// storeExtents puts extents back in the inode, in the inode itself if they fit and in leaf blocks if
+// not. Leaf blocks the file already has get reused and any left over are freed. The caller writes
+// the inode back
+func (fsys *FileSys) storeExtents(file *INode, extents []Extent) error {
+	oldLeaves := []int{}
+	if file.ExtentIndex {
+		for _, entry := range file.Extents {
+			if entry.Length > 0 {
+				oldLeaves = append(oldLeaves, entry.Start)
+			}
+		}
+	}
+	perLeaf := fsys.extentsPerBlock()
+	numLeaves := 0
+	if len(extents) > INLINE_EXTENTS {
+		numLeaves = (len(extents) + perLeaf - 1) / perLeaf
+	}
+	if numLeaves > INLINE_EXTENTS {
+		return fmt.Errorf("%d extents is more than an inode can hold, the disk is too fragmented: %w", len(extents), ErrFileTooLarge)
+	}
+
+	root := [INLINE_EXTENTS]Extent{}
+	if numLeaves == 0 {
+		copy(root[:], extents)
+	}
+	for leaf := 0; leaf < numLeaves; leaf++ {
+		var leafNum int
+		if leaf < len(oldLeaves) {
+			leafNum = oldLeaves[leaf]
+		} else {
+			newBlock, err := fsys.allocateNewBlock()
+			if err != nil {
+				return err
+			}
+			leafNum = newBlock
+		}
+		chunk := extents[leaf*perLeaf : min((leaf+1)*perLeaf, len(extents))]
+		leafBlock := make(ExtentBlock, perLeaf)
+		copy(leafBlock, chunk)
+		if err := fsys.writeExtentBlock(leafNum, leafBlock); err != nil {
+			return err
+		}
+		last := chunk[len(chunk)-1]
+		root[leaf] = Extent{FileBlock: chunk[0].FileBlock, Start: leafNum, Length: last.FileBlock + last.Length - chunk[0].FileBlock}
+	}
+	file.Extents = root
+	file.ExtentIndex = numLeaves > 0
+	if numLeaves < len(oldLeaves) {
+		return fsys.freeBlock(oldLeaves[numLeaves:]...)
+	}
+	return nil
+}
+
+func (fsys *FileSys) readExtentBlock(blockNum int) (ExtentBlock, error) {
+	blockBytes, err := fsys.readBlock(blockNum)
+	if err != nil {
+		return nil, err
+	}
+	var leafBlock ExtentBlock
+	if err := leafBlock.UnmarshalBinary(blockBytes); err != nil {
+		return nil, fmt.Errorf("extent block %d: %w", blockNum, err)
+	}
+	return leafBlock, nil
+}
+
+func (fsys *FileSys) writeExtentBlock(blockNum int, leafBlock ExtentBlock) error {
+	blockBytes, err := leafBlock.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return fsys.writeBlock(blockNum, blockBytes)
+}
@@ -0,0 +1,65 @@
+package FileSystem
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExtentWriteFarPastEnd(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{InodeFormat: INODE_EXTENTS})
+	before := countFreeBlocks(t, fsys)
+	createFile(t, fsys, "/f", 3000)
+	file := openFile(t, fsys, "/f")
+	if _, err := file.WriteAt([]byte("x"), 1<<30); err != nil {
+		t.Fatalf("write far past the end: %v", err)
+	}
+	file.Close()
+	//3 blocks for what was there and 1 for the x, the gap is a hole
+	if used := before - countFreeBlocks(t, fsys); used != 4 {
+		t.Fatalf("the file took %d blocks, want 4", used)
+	}
+	inode, _, err := fsys.Lookup("/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inode.Size != 1<<30+1 {
+		t.Fatalf("size is %d, want %d", inode.Size, 1<<30+1)
+	}
+	file = openFile(t, fsys, "/f")
+	got := make([]byte, 2)
+	if _, err := file.ReadAt(got, 1<<30-1); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	if got[0] != 0 || got[1] != 'x' {
+		t.Fatalf("read %q around the end, want a zero from the hole and the x", got)
+	}
+	checkClean(t, fsys)
+}
+
+func TestExtentWriteOutOfSpace(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{InodeFormat: INODE_EXTENTS})
+	before := countFreeBlocks(t, fsys)
+	createFile(t, fsys, "/f", 3000)
+	file := openFile(t, fsys, "/f")
+	written, err := file.WriteAt(make([]byte, 4000*1024), 5000)
+	if !errors.Is(err, ErrNoSpace) {
+		t.Fatalf("write bigger than the disk: got %v, want ErrNoSpace", err)
+	}
+	file.Close()
+	inode, _, err := fsys.Lookup("/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inode.Size != 5000+written {
+		t.Fatalf("size is %d after writing %d bytes at 5000", inode.Size, written)
+	}
+	checkClean(t, fsys)
+	//nothing past what got written may stay claimed
+	if err := fsys.Remove("/f"); err != nil {
+		t.Fatal(err)
+	}
+	if after := countFreeBlocks(t, fsys); after != before {
+		t.Fatalf("%d blocks free after removing the file, want %d", after, before)
+	}
+}
@@ -0,0 +1,158 @@
+package FileSystem
+
+import (
+	"fmt"
+	"io"
+)
+
+// File is an open file handed out by Open. It remembers where the next Read or Write
+// happens, so a file can be read or written a piece at a time.
+type File struct {
+	fsys     *FileSys
+	inode    INode
+	inodeNum int
+	mode     int
+	offset   int64
+	closed   bool
+}
+
+var (
+	_ io.Reader   = (*File)(nil)
+	_ io.Writer   = (*File)(nil)
+	_ io.Seeker   = (*File)(nil)
+	_ io.ReaderAt = (*File)(nil)
+	_ io.WriterAt = (*File)(nil)
+	_ io.Closer   = (*File)(nil)
+)
+
+// INode is the file's inode as of the last call on the handle
+func (file *File) INode() INode {
+	return file.inode
+}
+
+// InodeNum is the file's inode number
+func (file *File) InodeNum() int {
+	return file.inodeNum
+}
+
+// refresh rereads the inode so changes made through other handles are seen
+func (file *File) refresh() error {
+	if file.closed {
+		return ErrClosed
+	}
+	inode, err := file.fsys.getInodeFromDisk(file.inodeNum)
+	if err != nil {
+		return err
+	}
+	if !inode.IsValid {
+		return ErrNotFound //someone unlinked it out from under us
+	}
+	file.inode = inode
+	return nil
+}
+
+func (file *File) Read(p []byte) (int, error) {
+	bytesRead, err := file.ReadAt(p, file.offset)
+	file.offset += int64(bytesRead)
+	return bytesRead, err
+}
+
+func (file *File) ReadAt(p []byte, off int64) (int, error) {
+	if err := file.refresh(); err != nil {
+		return 0, err
+	}
+	if file.mode&READ == 0 {
+		return 0, fmt.Errorf("read: %w", ErrBadMode)
+	}
+	if file.inode.IsDirectory {
+		return 0, ErrIsDir
+	}
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	return file.fsys.readAt(&file.inode, p, off)
+}
+
+func (file *File) Write(p []byte) (int, error) {
+	if file.mode&APPEND != 0 {
+		//appends always land at the current end of the file, even if someone else grew it
+		if err := file.refresh(); err != nil {
+			return 0, err
+		}
+		file.offset = int64(file.inode.Size)
+	}
+	bytesWritten, err := file.writeAt(p, file.offset)
+	file.offset += int64(bytesWritten)
+	return bytesWritten, err
+}
+
+func (file *File) WriteAt(p []byte, off int64) (int, error) {
+	if file.mode&APPEND != 0 {
+		return 0, fmt.Errorf("write at offset: %w: file opened with APPEND", ErrBadMode)
+	}
+	return file.writeAt(p, off)
+}
+
+func (file *File) writeAt(p []byte, off int64) (bytesWritten int, err error) {
+	file.fsys.begin()
+	defer func() { err = file.fsys.commit(err) }()
+
+	if err := file.refresh(); err != nil {
+		return 0, err
+	}
+	if file.mode&WRITE == 0 {
+		return 0, fmt.Errorf("write: %w", ErrBadMode)
+	}
+	if file.inode.IsDirectory {
+		return 0, ErrIsDir
+	}
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	return file.fsys.writeAt(&file.inode, file.inodeNum, p, off)
+}
+
+// Truncate changes the size of the file, the offset stays where it is even if that is now past the end
+func (file *File) Truncate(size int64) (err error) {
+	file.fsys.begin()
+	defer func() { err = file.fsys.commit(err) }()
+
+	if err := file.refresh(); err != nil {
+		return err
+	}
+	if file.mode&WRITE == 0 {
+		return fmt.Errorf("truncate: %w", ErrBadMode)
+	}
+	if file.inode.IsDirectory {
+		return ErrIsDir
+	}
+	return file.fsys.truncate(&file.inode, file.inodeNum, int(size))
+}
+
+func (file *File) Seek(offset int64, whence int) (int64, error) {
+	if err := file.refresh(); err != nil {
+		return 0, err
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += file.offset
+	case io.SeekEnd:
+		offset += int64(file.inode.Size)
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative offset %d", offset)
+	}
+	file.offset = offset
+	return offset, nil
+}
+
+func (file *File) Close() error {
+	if file.closed {
+		return ErrClosed
+	}
+	file.closed = true
+	return nil
+}
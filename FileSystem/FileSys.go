@@ -1,56 +1,133 @@
 package FileSystem
 
 import (
-	"bytes"
-	"encoding/gob"
 	"errors"
 	"fmt"
-	"log"
-	"strings"
+	"io"
 	"time"
 )
 
 // Disk
 // first index in number of blocks
 // second is block size
-// I need 6144 blocks for the data - one for the superblock
-// I'll cheese the 'bitmaps' as booleans so I need 6144 bytes (6 blocks) for the data 'bitmap'
-// and I'll need inodes and an inode bitmap. I'll setup my inodes to be 64 bytes and if
-// I have 256 of them, then I need 64 blocks for inodes
-// furthermore I'll need 1 block for the inode 'bitmap'
-
-var Disk [66184][BLOCK_SIZE]byte
-var RootFolder INode
+// the bitmaps are real bitmaps now (see Bitmap.go), one bit per block, so the free block bitmap
+// for 66184 blocks of 1024 bytes needs 9 blocks and the inode bitmap fits in 1
+// I'll need inodes too. They are stored in a fixed 128 byte layout (see Encoding.go), so
+// 256 of them need 32 blocks
+// the blocks themselves live on a BlockDevice (see BlockDevice.go) rather than in a global array
+// the disk size, block size and number of inodes can all be picked when formatting (see Format.go),
+// the superblock records them, the ones here are just the defaults
+// after the inodes comes a journal of JOURNAL_BLOCKS blocks, so an operation's changes to the
+// bitmaps, inodes and folders reach the disk all together even if we crash, or for the few
+// operations too big for it, in pieces that each leave the disk consistent (see Journal.go)
 
 const (
-	INODE_SIZE       = 512 //even though Inodes are only 64 bytes, encoded they take up 170, and need power of 2
-	BLOCK_SIZE       = 1024
-	NUM_INODES       = 256
-	DATA_BLOCK_START = 140
+	INODE_SIZE = 128 //an encoded inode uses 48 bytes, the rest is room for new fields, and needs power of 2
+	BLOCK_SIZE = 1024
+	NUM_INODES = 256
+	NUM_BLOCKS = 66184
 )
 
+// FileSys is one filesystem living on one BlockDevice, so several can be used at once
+type FileSys struct {
+	dev         BlockDevice
+	RootFolder  INode
+	cwd         int          //inode number relative paths start from, 0 means the root folder
+	allocPolicy int          //FIRST_FIT or NEXT_FIT, see Allocator.go
+	nextFit     int          //block number NEXT_FIT starts looking from, 0 means the start of the data blocks
+	blockBitmap Bitmap       //the allocator's copy of the free block bitmap, nil until it is needed
+	lowestFree  int          //no data block below this one is free, so FIRST_FIT starts looking here
+	sblock      *SuperBlock  //copy of block 0 so it doesn't get decoded on every call, nil until it is read
+	tx          *transaction //the operation in progress, its writes are held here until it commits (see Journal.go)
+}
+
+// New wraps a device in a FileSys. The device still has to be formatted with
+// InitializeFileSystem (or already hold a filesystem) before it can be used
+func New(dev BlockDevice) *FileSys {
+	return &FileSys{dev: dev}
+}
+
+// blockSize is how many bytes are in each block, it comes from the device
+func (fsys *FileSys) blockSize() int {
+	return fsys.dev.BlockSize()
+}
+
+func (fsys *FileSys) readBlock(blockNum int) ([]byte, error) {
+	block := make([]byte, fsys.blockSize())
+	if fsys.tx != nil && fsys.tx.blocks[blockNum] != nil {
+		copy(block, fsys.tx.blocks[blockNum]) //written earlier in this operation and not stored yet
+		return block, nil
+	}
+	if err := fsys.dev.ReadBlock(blockNum, block); err != nil {
+		return block, fmt.Errorf("unable to read block %d: %w", blockNum, err)
+	}
+	return block, nil
+}
+
+// writeBlock is for the filesystem's own blocks, inside an operation they wait in the transaction
+// so they reach the disk all together or not at all
+func (fsys *FileSys) writeBlock(blockNum int, data []byte) error {
+	if fsys.tx != nil {
+		fsys.tx.hold(blockNum, data, fsys.blockSize())
+		return nil
+	}
+	return fsys.writeBlockNow(blockNum, data)
+}
+
+// writeDataBlock is for what's inside files. That goes straight to the disk even inside an
+// operation, the same as a real journaling filesystem in ordered mode, since it would fill the
+// journal up and a block nothing committed points at yet can't hurt anything. The exception is a
+// block this operation freed, something committed may still point at it
+func (fsys *FileSys) writeDataBlock(blockNum int, data []byte) error {
+	if fsys.tx != nil {
+		if fsys.tx.freed[blockNum] {
+			return fsys.writeBlock(blockNum, data)
+		}
+		fsys.tx.drop(blockNum) //this write is newer than anything held for the block
+	}
+	return fsys.writeBlockNow(blockNum, data)
+}
+
+// writeBlockNow writes to the device whatever transaction is going on
+func (fsys *FileSys) writeBlockNow(blockNum int, data []byte) error {
+	if err := fsys.dev.WriteBlock(blockNum, data); err != nil {
+		return fmt.Errorf("unable to write block %d: %w", blockNum, err)
+	}
+	return nil
+}
+
 type SuperBlock struct {
 	INodeStart       int //the block location of the beginning of the inodes
 	RootDirInode     int //the inode number of the root folder
-	FreeBlockStart   int //the block number where the beginning of the booleans for the free blocks is found
-	InodeBitmapStart int //block number of the inode 'bitmap'
+	FreeBlockStart   int //the block number where the free block bitmap starts, it runs up to INodeStart
+	InodeBitmapStart int //block number of the inode bitmap
 	DataBlockStart   int //the block number of the beginning of the datablocks
+	TotalBlocks      int //how many blocks the disk has, the free block bitmap has a bit for each
+	BlockSize        int //bytes in each block
+	InodeSize        int //bytes each inode takes up in the inode blocks
+	NumInodes        int //how many inodes there are, the inode bitmap has a bit for each
+	InodeFormat      int //INODE_BLOCKS or INODE_EXTENTS, how new files keep track of their blocks
+	JournalStart     int //the block number of the journal header, the journal sits between the inodes and the datablocks
+	JournalBlocks    int //how many blocks the journal has including its header, 0 for a disk without one
 }
 
-var RootInode *INode
-
 type INode struct {
 	IsValid        bool //true if this inode is a real file
 	IsDirectory    bool //true if this file is actually a directory entry
-	Version        int  //at the moment this is here mostly to make the inodes be 64 bytes
+	Version        int  //stored but not used yet, every inode made here has 0
+	LinkCount      int  //how many folder entries name this file (not counting . and ..), it is freed when that gets to 0
+	Size           int  //number of bytes actually stored in the file, the last block is usually only partly used
 	DirectBlock1   int
 	DirectBlock2   int
 	DirectBlock3   int
-	IndirectBlock  int
+	IndirectBlock  int //block of pointers to data blocks
+	DoubleIndirect int //block of pointers to indirect blocks
+	TripleIndirect int //block of pointers to double indirect blocks
 	CreateTime     int64
 	LastModifyTime int64
-	DirectBlocks   []int
-	Children       map[string]*INode
+	UsesExtents    bool                   //the blocks are found through Extents instead of the block pointers
+	ExtentIndex    bool                   //Extents point at leaf blocks of extents rather than straight at data
+	Extents        [INLINE_EXTENTS]Extent //see Extent.go
 }
 
 type DirectoryEntry struct {
@@ -58,108 +135,152 @@ type DirectoryEntry struct {
 	Name  [20]byte //I suggested 12 in class, but I realize that 20 will make this an even 32 bytes
 }
 
-type DirectoryBlock [32]DirectoryEntry
+type DirectoryBlock []DirectoryEntry //as many entries as fit in a block, see newDirectoryBlock
+
+type IndirectBlock []int //block numbers are stored in 4 bytes each, so a block holds block size/4 of them
 
-type IndirectBlock [128]int
+// newDirectoryBlock makes an empty directory block that fills a whole block
+func (fsys *FileSys) newDirectoryBlock() DirectoryBlock {
+	return make(DirectoryBlock, fsys.blockSize()/DIRECTORY_ENTRY_SIZE)
+}
+
+// pointersPerBlock is how many block numbers fit in an indirect block
+func (fsys *FileSys) pointersPerBlock() int {
+	return fsys.blockSize() / 4
+}
 
+// Open modes, these are bit flags so they can be combined (WRITE|CREATE|TRUNC and so on)
 const (
-	CREATE = iota
-	READ
-	WRITE
-	APPEND
+	READ   = 1 << iota //the file can be read
+	WRITE              //the file can be written
+	APPEND             //every write goes to the end of the file, implies WRITE
+	CREATE             //make the file if it isn't there, on its own it means read and write
+	EXCL               //with CREATE, fail with ErrExist if the file is already there
+	TRUNC              //with WRITE, throw away the file's contents when it's opened
 )
 
-func InitializeFileSystem() {
-	//explicitly zero the filesystem - this shouldn't be needed
-	for blockLoc := range Disk {
-		for byteLoc := range Disk[blockLoc] {
-			Disk[blockLoc][byteLoc] = 0
+// openMode fills in the access bits the caller left implied, and rejects combinations that make no sense
+func openMode(mode int) (int, error) {
+	if mode&(READ|WRITE|APPEND) == 0 {
+		if mode&CREATE != 0 {
+			mode |= READ | WRITE
+		} else {
+			mode |= READ
 		}
 	}
-
-	//order on the Disk will be Superblock in block 0, inode bitmap in block 1, free block bitmap  blocks 2-7
-	//inodes in blocks 8-39 and datablocks in blocks 40-end
-
-	supBlock := SuperBlock{
-		INodeStart:       8,
-		RootDirInode:     1,
-		FreeBlockStart:   2,
-		InodeBitmapStart: 1,
-		DataBlockStart:   DATA_BLOCK_START,
+	if mode&APPEND != 0 {
+		mode |= WRITE
+	}
+	if mode&TRUNC != 0 && mode&WRITE == 0 {
+		return mode, fmt.Errorf("%w: TRUNC needs WRITE", ErrBadMode)
 	}
-	superblockBytes := EncodeToBytes(supBlock)
-	copy(Disk[0][:], superblockBytes)
-	createInodeBitmap(supBlock)
-	createFreeBlockBitmap(supBlock)
-	createInodes(supBlock)
-	createRootDir(supBlock)
+	return mode, nil
 }
 
-func createFreeBlockBitmap(block SuperBlock) {
+// InitializeFileSystem formats the whole device with the default number of inodes
+func (fsys *FileSys) InitializeFileSystem() error {
+	return fsys.Format(FormatOptions{})
+}
+
+func (fsys *FileSys) createFreeBlockBitmap(block SuperBlock) error {
 	//unlike the inode bitmap, the free block bitmap will take up multiple blocks
-	wholeFreeBlockBitmap := make([][BLOCK_SIZE]bool, 1)
-	for bitmapBlock := block.InodeBitmapStart; bitmapBlock < block.INodeStart; bitmapBlock++ {
-		var currentFreeBlockBitmap [1024]bool //should be all false by default
-		wholeFreeBlockBitmap = append(wholeFreeBlockBitmap, currentFreeBlockBitmap)
+	freeBlockBitmap := NewBitmap(block.TotalBlocks)
+	for blockNum := 0; blockNum < block.DataBlockStart; blockNum++ {
+		freeBlockBitmap.Set(blockNum) //the superblock, bitmaps and inodes are never handed out
 	}
-	writeFreeBlockBitmapToDisk(wholeFreeBlockBitmap, block)
+	return fsys.writeFreeBlockBitmapToDisk(freeBlockBitmap, block)
 }
 
-func createInodeBitmap(block SuperBlock) {
-	//the inode bitmap will be in block 1 and will hold NUM_INODES booleans
-	var inodeBitmap [NUM_INODES]bool //all set to zero by default
-	writeInodeBitmapToDisk(inodeBitmap, block)
+func (fsys *FileSys) createInodeBitmap(block SuperBlock) error {
+	//the inode bitmap starts in block 1 and will hold NumInodes bits
+	return fsys.writeInodeBitmapToDisk(NewBitmap(block.NumInodes), block)
 }
 
-func createInodes(sblock SuperBlock) {
-	//here we will create all 256/NUM_INODES INodes in the filesystem as invalid files
-	//fix this
-	for iNodeNum := 0; iNodeNum < NUM_INODES; iNodeNum++ {
+func (fsys *FileSys) createInodes(sblock SuperBlock) error {
+	//here we will create all NumInodes INodes in the filesystem as invalid files
+	for iNodeNum := 0; iNodeNum < sblock.NumInodes; iNodeNum++ {
 		currentInode := INode{} //make empty with all fields having false/zero value
-		inodeBytes := EncodeToBytes(currentInode)
-		inodeblock := (iNodeNum * INODE_SIZE / BLOCK_SIZE) + sblock.INodeStart //this is all integer division, so result is floor division
-		inodeOffSet := iNodeNum * INODE_SIZE % BLOCK_SIZE
-		copy(Disk[inodeblock][inodeOffSet:inodeOffSet+INODE_SIZE], inodeBytes)
+		if err := fsys.writeInodeToDisk(&currentInode, iNodeNum, sblock); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-func createRootDir(sblock SuperBlock) {
+func (fsys *FileSys) createRootDir(sblock SuperBlock) error {
 	//rather than reading the existing inode in, since I know they are all empty, I'll make a new one and write it to disk
+	rootBlockNum := sblock.DataBlockStart //since this happens before any other allocation, just grab the first data block
 	rootFolder := INode{
 		IsValid:        true,
 		IsDirectory:    true,
 		Version:        0,
-		DirectBlock1:   DATA_BLOCK_START + 1, //since this happens before any other allocation, just grab block 40
+		LinkCount:      1,
+		DirectBlock1:   rootBlockNum,
 		DirectBlock2:   0,
 		DirectBlock3:   0,
 		IndirectBlock:  0,
+		Size:           sblock.BlockSize,
 		CreateTime:     time.Now().Unix(),
 		LastModifyTime: time.Now().Unix(),
 	}
+	if sblock.InodeFormat == INODE_EXTENTS {
+		rootFolder.DirectBlock1 = 0
+		rootFolder.UsesExtents = true
+		rootFolder.Extents[0] = Extent{FileBlock: 0, Start: rootBlockNum, Length: 1}
+	}
 	//now we need to mark the root inode as used
-	inodeBitmap := ReadINodeBitmap(sblock)
-	inodeBitmap[sblock.RootDirInode] = true //claim the inode for the root folder
-	writeInodeBitmapToDisk(inodeBitmap, sblock)
-	//and let's claim that direct block 40
-	freeBlockBitmap := ReadFreeBlockBitmap(sblock)
-	freeBlockBitmap[0][rootFolder.DirectBlock1] = true
-	writeFreeBlockBitmapToDisk(freeBlockBitmap, sblock)
-	rootBlock, _ := CreateDirectoryFile(0, sblock.RootDirInode)
-	rootBlockBytes := EncodeToBytes(rootBlock)
-	copy(Disk[rootFolder.DirectBlock1][:], rootBlockBytes)
-	rootFolderAsBytes := EncodeToBytes(rootFolder)
-	copy(Disk[sblock.INodeStart][INODE_SIZE*sblock.RootDirInode:INODE_SIZE*sblock.RootDirInode+INODE_SIZE], rootFolderAsBytes)
-	RootFolder = rootFolder
-}
-
-func CreateDirectoryFile(parentInode int, folderinode int) (retBlock DirectoryBlock, currentInode INode) {
+	inodeBitmap, err := fsys.ReadINodeBitmap(sblock)
+	if err != nil {
+		return err
+	}
+	inodeBitmap.Set(sblock.RootDirInode) //claim the inode for the root folder
+	if err := fsys.writeInodeBitmapToDisk(inodeBitmap, sblock); err != nil {
+		return err
+	}
+	//and let's claim that first data block
+	freeBlockBitmap, err := fsys.ReadFreeBlockBitmap(sblock)
+	if err != nil {
+		return err
+	}
+	freeBlockBitmap.Set(rootBlockNum)
+	if err := fsys.writeFreeBlockBitmapToDisk(freeBlockBitmap, sblock); err != nil {
+		return err
+	}
+	rootBlock, _, err := fsys.CreateDirectoryFile(0, sblock.RootDirInode)
+	if err != nil {
+		return err
+	}
+	rootBlock[1].Inode = sblock.RootDirInode //there is nothing above the root folder, so its .. is itself
+	rootBlockBytes, err := rootBlock.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := fsys.writeBlock(rootBlockNum, rootBlockBytes); err != nil {
+		return err
+	}
+	return fsys.writeInodeToDisk(&rootFolder, sblock.RootDirInode, sblock)
+}
+
+func (fsys *FileSys) CreateDirectoryFile(parentInode int, folderinode int) (retBlock DirectoryBlock, currentInode INode, err error) {
+	fsys.begin()
+	defer func() { err = fsys.commit(err) }()
+
 	if parentInode != 0 { //handle root directory specially, for all others, mark as folder now
-		currentInode = getInodeFromDisk(folderinode) //we need to mark this as a folder now
+		currentInode, err = fsys.getInodeFromDisk(folderinode) //we need to mark this as a folder now
+		if err != nil {
+			return nil, INode{}, err
+		}
 		currentInode.IsDirectory = true
 		if !currentInode.IsValid {
 			currentInode.IsValid = true
 		}
-		writeInodeToDisk(&currentInode, folderinode, ReadSuperBlock())
+		sblock, err := fsys.ReadSuperBlock()
+		if err != nil {
+			return nil, INode{}, err
+		}
+		if err := fsys.writeInodeToDisk(&currentInode, folderinode, sblock); err != nil {
+			return nil, INode{}, err
+		}
 	}
 	dot := DirectoryEntry{
 		Inode: folderinode,
@@ -170,471 +291,568 @@ func CreateDirectoryFile(parentInode int, folderinode int) (retBlock DirectoryBl
 	}
 	dotdot.Name[0] = '.'
 	dotdot.Name[1] = '.'
-	return DirectoryBlock{dot, dotdot}, currentInode
+	retBlock = fsys.newDirectoryBlock()
+	retBlock[0], retBlock[1] = dot, dotdot
+	return retBlock, currentInode, nil
 }
 
-func writeFreeBlockBitmapToDisk(bitmap [][BLOCK_SIZE]bool, sblock SuperBlock) {
-	for loc, bitmapPart := range bitmap {
-		for blockLoc, bit := range bitmapPart {
-			if bit {
-				Disk[loc+sblock.FreeBlockStart][blockLoc] = 1
-			} else {
-				Disk[loc+sblock.FreeBlockStart][blockLoc] = 0
-			}
-		}
-	}
+func (fsys *FileSys) writeFreeBlockBitmapToDisk(bitmap Bitmap, sblock SuperBlock) error {
+	return fsys.writeBitmap(sblock.FreeBlockStart, bitmap)
 }
 
-func ReadFreeBlockBitmap(sblock SuperBlock) [][BLOCK_SIZE]bool {
-	//I decided to cheese this just a little to make life a little easier see below to do it right
-	freeBlockBitmap := make([][BLOCK_SIZE]bool, sblock.INodeStart-sblock.FreeBlockStart)
+func (fsys *FileSys) ReadFreeBlockBitmap(sblock SuperBlock) (Bitmap, error) {
+	return fsys.readBitmap(sblock.FreeBlockStart, sblock.TotalBlocks)
+}
 
-	for bitmapBlockNum := sblock.FreeBlockStart; bitmapBlockNum < sblock.INodeStart; bitmapBlockNum++ {
-		for bitLoc := 0; bitLoc < BLOCK_SIZE; bitLoc++ {
-			if Disk[bitmapBlockNum][bitLoc] != 0 {
-				freeBlockBitmap[bitmapBlockNum-sblock.FreeBlockStart][bitLoc] = true
-			} else {
-				freeBlockBitmap[bitmapBlockNum-sblock.FreeBlockStart][bitLoc] = false
-			}
-		}
-	}
-	return freeBlockBitmap
-}
-
-//this is my original - do it right version.
-//func ReadFreeBlockBitmap(sblock SuperBlock) []bool {
-//	freeBlockBitmap := make([]bool, BLOCK_SIZE)
-//	for bitmapBlock := sblock.FreeBlockStart; bitmapBlock < sblock.INodeStart; bitmapBlock++ {
-//		freeBlockBitmapPart := make([]bool, BLOCK_SIZE)
-//		err := json.Unmarshal(Disk[bitmapBlock][:], &freeBlockBitmapPart)
-//		if err != nil {
-//			log.Fatal(err)
-//		}
-//		freeBlockBitmap = append(freeBlockBitmap, freeBlockBitmapPart...)
-//	}
-//	return freeBlockBitmap
-//}
-
-func writeInodeBitmapToDisk(bitmap [NUM_INODES]bool, sblock SuperBlock) {
-	//I ended up having to copy bit by bit (bool by bool) there was no scope for being lazy
-	for loc, bit := range bitmap {
-		if bit {
-			Disk[sblock.InodeBitmapStart][loc] = 1
-		} else {
-			Disk[sblock.InodeBitmapStart][loc] = 0
-		}
-	}
+func (fsys *FileSys) writeInodeBitmapToDisk(bitmap Bitmap, sblock SuperBlock) error {
+	return fsys.writeBitmap(sblock.InodeBitmapStart, bitmap)
 }
 
-func ReadINodeBitmap(block SuperBlock) [NUM_INODES]bool {
-	var iNodeBitmap [NUM_INODES]bool
-	bitMapOnDisk := Disk[block.InodeBitmapStart]
-	for bitNum := 0; bitNum < NUM_INODES; bitNum++ {
-		iNodeBitmap[bitNum] = bitMapOnDisk[bitNum] != 0 //if the byte is zero, bit is false, non-zero is true
-	}
-	return iNodeBitmap
+func (fsys *FileSys) ReadINodeBitmap(block SuperBlock) (Bitmap, error) {
+	return fsys.readBitmap(block.InodeBitmapStart, block.NumInodes)
 }
 
-func ReadSuperBlock() SuperBlock {
+// ReadSuperBlock hands back the superblock, it is only decoded from block 0 the first time
+func (fsys *FileSys) ReadSuperBlock() (SuperBlock, error) {
+	if fsys.sblock != nil {
+		return *fsys.sblock, nil
+	}
 	sBlock := SuperBlock{}
-	decoder := gob.NewDecoder(bytes.NewReader(Disk[0][:]))
-	err := decoder.Decode(&sBlock)
+	superBlockBytes, err := fsys.readBlock(0)
 	if err != nil {
-		log.Fatal("Unable to Decode superblock - better blue Screen ", err)
+		return sBlock, err
+	}
+	if err := sBlock.UnmarshalBinary(superBlockBytes); err != nil {
+		return sBlock, err
+	}
+	if sBlock.BlockSize != fsys.blockSize() || sBlock.TotalBlocks > fsys.dev.NumBlocks() {
+		return sBlock, fmt.Errorf("%w: superblock says %d blocks of %d bytes, the device has %d blocks of %d bytes",
+			ErrCorrupt, sBlock.TotalBlocks, sBlock.BlockSize, fsys.dev.NumBlocks(), fsys.blockSize())
+	}
+	if sBlock.InodeFormat != INODE_BLOCKS && sBlock.InodeFormat != INODE_EXTENTS {
+		return sBlock, fmt.Errorf("%w: unknown inode format %d", ErrCorrupt, sBlock.InodeFormat)
 	}
-	return sBlock
+	if sBlock.JournalBlocks != 0 && (sBlock.JournalBlocks < MIN_JOURNAL_BLOCKS || sBlock.JournalStart+sBlock.JournalBlocks > sBlock.DataBlockStart) {
+		return sBlock, fmt.Errorf("%w: journal of %d blocks at block %d doesn't fit before the datablocks", ErrCorrupt, sBlock.JournalBlocks, sBlock.JournalStart)
+	}
+	if sBlock.InodeSize != INODE_SIZE {
+		return sBlock, fmt.Errorf("%w: inodes are %d bytes, only %d is understood", ErrCorrupt, sBlock.InodeSize, INODE_SIZE)
+	}
+	fsys.sblock = &sBlock
+	return sBlock, nil
 }
 
-// from https://gist.github.com/SteveBate/042960baa7a4795c3565
-func EncodeToBytes(p interface{}) []byte {
-
-	buf := bytes.Buffer{}
-	enc := gob.NewEncoder(&buf)
-	err := enc.Encode(p)
+// Open finds (or with CREATE makes) name in parentDir and hands back a File to read and write it with
+func (fsys *FileSys) Open(mode int, name string, parentDir INode) (*File, error) {
+	mode, err := openMode(mode)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("open %s: %w", name, err)
+	}
+	fsys.begin()
+	fileInode, inodeNum, err := fsys.openInode(mode, name, parentDir)
+	if err = fsys.commit(err); err != nil {
+		return nil, err
 	}
-	return buf.Bytes()
+	return &File{fsys: fsys, inode: fileInode, inodeNum: inodeNum, mode: mode}, nil
 }
 
-// Open return values are first INodeStructure and second INode Number
-func Open(mode int, name string, parentDir INode) (INode, int) {
+// openInode return values are first INodeStructure and second INode Number
+func (fsys *FileSys) openInode(mode int, name string, parentDir INode) (INode, int, error) {
 	if !parentDir.IsDirectory || !parentDir.IsValid {
-		log.Fatal("Tried to open file with invalid directory")
+		return INode{}, 0, fmt.Errorf("open %s: %w", name, ErrNotDir)
 	}
-	BlockWhereWeFindDirectoryEntry := parentDir.DirectBlock1 //I'm going to cheat here and only check direct block one since we would need more than 30 files otherwise
-	DirectoryBlockBytes := Disk[BlockWhereWeFindDirectoryEntry]
-	directoryEntryBlock := DirectoryBlock{}
-	decoder := gob.NewDecoder(bytes.NewReader(DirectoryBlockBytes[:]))
-	err := decoder.Decode(&directoryEntryBlock)
+	entry, found, err := fsys.findDirectoryEntry(parentDir, name)
 	if err != nil {
-		log.Fatal("Error decoding Directory block opening file ", name, ": ", err)
+		return INode{}, 0, fmt.Errorf("open %s: %w", name, err)
 	}
-	validDirectoryEntries := 0
-	for _, entry := range directoryEntryBlock {
-		//not really distinguishing read vs write here.
-		if string(entry.Name[:len(name)]) == name {
-			return getInodeFromDisk(entry.Inode), entry.Inode //if file is here, I'll just return it and the Inode Number for now
-		}
-		if entry.Inode == 0 && entry.Name[0] != '.' && entry.Name[1] != '.' { //once we get to invalid entries, get out of loop
-			break
-		}
-		validDirectoryEntries++
+	if found {
+		return fsys.openExisting(mode, name, entry.Inode)
 	}
 	//if we got here then the file wasn't in the directory
-	if mode == CREATE {
-		newInode, newInodeNum := createNewInode(ReadSuperBlock())
-		newFile := DirectoryEntry{
-			Inode: newInodeNum,
+	if mode&CREATE == 0 {
+		return INode{}, 0, fmt.Errorf("open %s: %w", name, ErrNotFound)
+	}
+	newFile, err := newDirectoryEntry(name, 0)
+	if err != nil {
+		return INode{}, 0, fmt.Errorf("open %s: %w", name, err)
+	}
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return INode{}, 0, err
+	}
+	newInode, newInodeNum, err := fsys.createNewInode(sblock)
+	if err != nil {
+		return INode{}, 0, fmt.Errorf("open %s: %w", name, err)
+	}
+	newFile.Inode = newInodeNum
+	if err := fsys.addDirectoryEntry(parentDir, newFile); err != nil {
+		//give the inode back, nothing points at it
+		if freeErr := fsys.freeInode(newInodeNum, sblock); freeErr != nil {
+			return INode{}, 0, freeErr
 		}
-		for num, char := range name {
-			if num >= 20 {
-				break
-			}
-			newFile.Name[num] = byte(char)
+		return INode{}, 0, fmt.Errorf("open %s: %w", name, err)
+	}
+	return newInode, newInodeNum, nil
+}
+
+// openExisting applies the open mode to a file that is already in the folder
+func (fsys *FileSys) openExisting(mode int, name string, inodeNum int) (INode, int, error) {
+	fileInode, err := fsys.getInodeFromDisk(inodeNum)
+	if err != nil {
+		return INode{}, 0, err
+	}
+	if mode&CREATE != 0 && mode&EXCL != 0 {
+		return INode{}, 0, fmt.Errorf("open %s: %w", name, ErrExist)
+	}
+	if fileInode.IsDirectory && mode&WRITE != 0 {
+		return INode{}, 0, fmt.Errorf("open %s: %w", name, ErrIsDir)
+	}
+	if mode&TRUNC != 0 && (fileInode.Size > 0 || fileInode.DirectBlock1 != 0 || fileInode.Extents[0].Length != 0) {
+		if err := fsys.truncate(&fileInode, inodeNum, 0); err != nil {
+			return INode{}, 0, err
 		}
-		directoryEntryBlock[validDirectoryEntries] = newFile
-		//write the directory entry back to the disk block
-		currentDirectoryBlockBytes := EncodeToBytes(directoryEntryBlock)
-		copy(Disk[parentDir.DirectBlock1][:], currentDirectoryBlockBytes)
-		return newInode, newInodeNum
 	}
-	return INode{}, 0 //if we got here, return invalid/0 inode
+	return fileInode, inodeNum, nil
 }
 
 // return value will be the INode data structure, and the Inode Number
-func createNewInode(sBlock SuperBlock) (INode, int) {
-	inodeBitmap := ReadINodeBitmap(sBlock)
-	freeInodeLoc := sBlock.RootDirInode               //we will begin looking for a free inode starting with the root node
-	for ; freeInodeLoc < NUM_INODES; freeInodeLoc++ { //there are only 25 possible inodes
-		if inodeBitmap[freeInodeLoc] == false { //once we find an unused one stop
-			inodeBitmap[freeInodeLoc] = true
+func (fsys *FileSys) createNewInode(sBlock SuperBlock) (INode, int, error) {
+	inodeBitmap, err := fsys.ReadINodeBitmap(sBlock)
+	if err != nil {
+		return INode{}, 0, err
+	}
+	freeInodeLoc := sBlock.RootDirInode                     //we will begin looking for a free inode starting with the root node
+	for ; freeInodeLoc < sBlock.NumInodes; freeInodeLoc++ { //there are only NumInodes possible inodes
+		if !inodeBitmap.IsSet(freeInodeLoc) { //once we find an unused one stop
+			inodeBitmap.Set(freeInodeLoc)
 			break
 		}
 	}
-	if freeInodeLoc >= 511 {
-		log.Fatal("All out of Inodes") //in a real file system I would return the 0/invalid inode
+	if freeInodeLoc >= sBlock.NumInodes {
+		return INode{}, 0, ErrNoInodes
+	}
+	if err := fsys.writeBitmapBlock(sBlock.InodeBitmapStart, inodeBitmap, freeInodeLoc); err != nil {
+		return INode{}, 0, err
 	}
-	writeInodeBitmapToDisk(inodeBitmap, sBlock) 
 	newInode := INode{
 		IsValid:        true,
 		IsDirectory:    false,
 		Version:        0,
+		LinkCount:      1,
 		DirectBlock1:   0,
 		DirectBlock2:   0,
 		DirectBlock3:   0,
 		IndirectBlock:  0,
 		CreateTime:     time.Now().Unix(),
 		LastModifyTime: time.Now().Unix(),
+		UsesExtents:    sBlock.InodeFormat == INODE_EXTENTS,
 	}
-	writeInodeToDisk(&newInode, freeInodeLoc, sBlock)
-	return newInode, freeInodeLoc
+	if err := fsys.writeInodeToDisk(&newInode, freeInodeLoc, sBlock); err != nil {
+		return INode{}, 0, err
+	}
+	return newInode, freeInodeLoc, nil
 }
 
-func writeInodeToDisk(inode *INode, InodeNum int, sblock SuperBlock) {
-	InodeAsBytes := EncodeToBytes(inode)
-	InodeBlock := InodeNum / (BLOCK_SIZE / INODE_SIZE) 
-	InodeLocInBlock := InodeNum % (BLOCK_SIZE / INODE_SIZE)
-	copy(Disk[sblock.INodeStart+InodeBlock][INODE_SIZE*InodeLocInBlock:INODE_SIZE*InodeLocInBlock+INODE_SIZE], InodeAsBytes)
+func (fsys *FileSys) writeInodeToDisk(inode *INode, InodeNum int, sblock SuperBlock) error {
+	InodeAsBytes, err := inode.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	InodeBlock := InodeNum / (sblock.BlockSize / sblock.InodeSize)
+	InodeLocInBlock := InodeNum % (sblock.BlockSize / sblock.InodeSize)
+	blockBytes, err := fsys.readBlock(sblock.INodeStart + InodeBlock)
+	if err != nil {
+		return err
+	}
+	copy(blockBytes[sblock.InodeSize*InodeLocInBlock:sblock.InodeSize*InodeLocInBlock+sblock.InodeSize], InodeAsBytes)
+	if err := fsys.writeBlock(sblock.INodeStart+InodeBlock, blockBytes); err != nil {
+		return err
+	}
+	if InodeNum == sblock.RootDirInode {
+		fsys.RootFolder = *inode //keep the cached copy of the root folder in step with the disk
+	}
+	return nil
 }
 
-func getInodeFromDisk(inodeNum int) INode {
-	INodeBlock := inodeNum / (BLOCK_SIZE / INODE_SIZE)
-	InodeOffset := inodeNum % (BLOCK_SIZE / INODE_SIZE)
-	sblock := ReadSuperBlock()
+func (fsys *FileSys) getInodeFromDisk(inodeNum int) (INode, error) {
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return INode{}, err
+	}
+	if inodeNum <= 0 || inodeNum >= sblock.NumInodes {
+		return INode{}, fmt.Errorf("%w: inode number %d out of range", ErrCorrupt, inodeNum)
+	}
+	INodeBlock := inodeNum / (sblock.BlockSize / sblock.InodeSize)
+	InodeOffset := inodeNum % (sblock.BlockSize / sblock.InodeSize)
 	InodeFromDisk := INode{}
-	InodeAsBytes := Disk[sblock.INodeStart+INodeBlock][InodeOffset*INODE_SIZE : (InodeOffset*INODE_SIZE)+INODE_SIZE]
-	decoder := gob.NewDecoder(bytes.NewReader(InodeAsBytes))
-	err := decoder.Decode(&InodeFromDisk)
+	blockBytes, err := fsys.readBlock(sblock.INodeStart + INodeBlock)
 	if err != nil {
-		log.Fatal("Error decoding Inode ", inodeNum, " from disk - better blue Screen", err)
+		return INode{}, err
+	}
+	InodeAsBytes := blockBytes[InodeOffset*sblock.InodeSize : (InodeOffset*sblock.InodeSize)+sblock.InodeSize]
+	if err := InodeFromDisk.UnmarshalBinary(InodeAsBytes); err != nil {
+		return INode{}, fmt.Errorf("inode %d: %w", inodeNum, err)
 	}
-	return InodeFromDisk
+	return InodeFromDisk, nil
 }
 
-func Unlink(inodeNumToDelete int, parentDir INode) error {
-	blockWhereDirectoryEntryIsFound := parentDir.DirectBlock1
-	directoryBlockBytes := Disk[blockWhereDirectoryEntryIsFound]
-	directoryEntryBlock := DirectoryBlock{}
-	decoder := gob.NewDecoder(bytes.NewReader(directoryBlockBytes[:]))
+// Unlink removes the folder's entry for the inode, the file itself only goes when that was its last name
+func (fsys *FileSys) Unlink(inodeNumToDelete int, parentDir INode) (err error) {
+	fsys.begin()
+	defer func() { err = fsys.commit(err) }()
 
-	if err := decoder.Decode(&directoryEntryBlock); err != nil {
-		return fmt.Errorf("error decoding directory block: %w", err)
+	entries, err := fsys.readDirectoryEntries(parentDir)
+	if err != nil {
+		return err
 	}
-
-	// Attempt to find and clear the inode entry
 	found := false
-	for i, entry := range directoryEntryBlock {
-		if entry.Inode == inodeNumToDelete {
-			directoryEntryBlock[i] = DirectoryEntry{}
-			found = true
-			break
-		}
+	for _, entry := range entries {
+		found = found || entry.Inode == inodeNumToDelete
 	}
-
 	if !found {
-		return fmt.Errorf("inode %d not found in directory", inodeNumToDelete)
+		return fmt.Errorf("inode %d not found in directory: %w", inodeNumToDelete, ErrNotFound)
 	}
-
-	// Update the inode bitmap and inode structure
-	inodeBitmap := ReadINodeBitmap(ReadSuperBlock())
-	inodeBitmap[inodeNumToDelete] = false
-	writeInodeBitmapToDisk(inodeBitmap, ReadSuperBlock())
-
-	inodeStruct := getInodeFromDisk(inodeNumToDelete)
-	inodeStruct.IsValid = false
-	writeInodeToDisk(&inodeStruct, inodeNumToDelete, ReadSuperBlock())
-
-	// Write the updated directory block back to disk
-	updatedDirectoryBlockBytes := EncodeToBytes(directoryEntryBlock)
-	copy(Disk[blockWhereDirectoryEntryIsFound][:], updatedDirectoryBlockBytes)
-
-	return nil
+	if err := fsys.emptyLastName(inodeNumToDelete); err != nil {
+		return err
+	}
+	// Attempt to find and clear the inode entry
+	if _, err := fsys.removeDirectoryEntry(parentDir, func(entry DirectoryEntry) bool {
+		return entry.Inode == inodeNumToDelete
+	}); err != nil {
+		return err
+	}
+	return fsys.dropLink(inodeNumToDelete)
 }
 
-func Read(file *INode) string {
-	if !file.IsValid || file.IsDirectory {
-		fmt.Println("File is invalid or a directory")
-		return ""
+// emptyLastName gives back the blocks of a file that is about to lose its last name, while that
+// name still points at it. A big file takes more than one commit to empty (see shrinkFile), and a
+// crash between them has to leave the file in its folder, just shorter. Folders are emptied of
+// their entries before they go, which leaves them small enough to free along with the inode
+func (fsys *FileSys) emptyLastName(inodeNum int) error {
+	inode, err := fsys.getInodeFromDisk(inodeNum)
+	if err != nil {
+		return err
 	}
-	fileContents := strings.Builder{}
-	fmt.Printf("Reading direct block 1: %d\n", file.DirectBlock1)
-	firstBlock := Disk[file.DirectBlock1]
-	fileContents.Write(firstBlock[:])
-	fmt.Printf("Content from block 1: '%s'\n", string(firstBlock[:]))
+	if inode.IsDirectory || inode.LinkCount > 1 {
+		return nil
+	}
+	return fsys.shrinkFile(&inode, inodeNum, 0)
+}
 
-	if file.DirectBlock2 == 0 {
-		return fileContents.String()
+// dropLink is for when one of the file's entries has been removed. The file only goes once it has
+// no entries left, until then it just has one name fewer
+func (fsys *FileSys) dropLink(inodeNum int) error {
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return err
+	}
+	inode, err := fsys.getInodeFromDisk(inodeNum)
+	if err != nil {
+		return err
 	}
-	fmt.Printf("Reading direct block 2: %d\n", file.DirectBlock2)
-	secondBlock := Disk[file.DirectBlock2]
-	fileContents.Write(secondBlock[:])
-	fmt.Printf("Content from block 2: '%s'\n", string(secondBlock[:]))
+	if inode.LinkCount > 1 {
+		inode.LinkCount--
+		return fsys.writeInodeToDisk(&inode, inodeNum, sblock)
+	}
+	// Update the inode bitmap and inode structure
+	return fsys.freeInode(inodeNum, sblock)
+}
 
-	if file.DirectBlock3 == 0 {
-		return fileContents.String()
+// freeInode marks the inode as invalid and gives it back to the inode bitmap
+func (fsys *FileSys) freeInode(inodeNum int, sblock SuperBlock) error {
+	inodeBitmap, err := fsys.ReadINodeBitmap(sblock)
+	if err != nil {
+		return err
+	}
+	inodeBitmap.Clear(inodeNum)
+	if err := fsys.writeBitmapBlock(sblock.InodeBitmapStart, inodeBitmap, inodeNum); err != nil {
+		return err
 	}
-	fmt.Printf("Reading direct block 3: %d\n", file.DirectBlock3)
-	thirdBlock := Disk[file.DirectBlock3]
-	fileContents.Write(thirdBlock[:])
-	fmt.Printf("Content from block 3: '%s'\n", string(thirdBlock[:]))
 
-	if file.IndirectBlock == 0 {
-		return fileContents.String()
+	inodeStruct, err := fsys.getInodeFromDisk(inodeNum)
+	if err != nil {
+		return err
 	}
-	fmt.Printf("Reading from indirect block: %d\n", file.IndirectBlock)
-	indirectBlockVal := getIndirectBlock(file)
-	for i, blockNum := range indirectBlockVal {
-		if blockNum == 0 {
-			break
-		}
-		fmt.Printf("Reading indirect block number %d: %d\n", i+1, blockNum)
-		blockData := Disk[blockNum][:]
-		fileContents.Write(blockData)
-		fmt.Printf("Content from indirect block %d: '%s'\n", i+1, string(blockData))
+	if err := fsys.freeBlocksFrom(&inodeStruct, 0); err != nil {
+		return err
 	}
-	return fileContents.String()
+	inodeStruct.IsValid = false
+	inodeStruct.Size = 0
+	inodeStruct.LinkCount = 0
+	return fsys.writeInodeToDisk(&inodeStruct, inodeNum, sblock)
 }
 
-func Write(file *INode, inodeNum int, content []byte) {
-	file.LastModifyTime = time.Now().Unix() //update last modify time
-	numCompleteBlocks := len(content) / BLOCK_SIZE
-	hasLeftovers := len(content)%BLOCK_SIZE > 0
-	block := 0
-	for ; block < numCompleteBlocks; block++ {
-		if block == 0 {
-			if file.DirectBlock1 == 0 {
-				file.DirectBlock1 = allocateNewBlock(ReadSuperBlock())
-			}
-			blockEnd := BLOCK_SIZE * (block + 1)
-			if blockEnd > len(content) {
-				blockEnd = len(content)
-			}
-			copy(Disk[file.DirectBlock1][:], content[BLOCK_SIZE*block:blockEnd])
-		} else if block == 1 {
-			if file.DirectBlock2 == 0 {
-				file.DirectBlock2 = allocateNewBlock(ReadSuperBlock())
-			}
-			blockEnd := BLOCK_SIZE * (block + 1)
-			if blockEnd > len(content) {
-				blockEnd = len(content)
-			}
-			copy(Disk[file.DirectBlock2][:], content[BLOCK_SIZE*block:blockEnd])
-		} else if block == 2 {
-			if file.DirectBlock3 == 0 {
-				file.DirectBlock3 = allocateNewBlock(ReadSuperBlock())
-			}
-			blockEnd := BLOCK_SIZE * (block + 1)
-			if blockEnd > len(content) {
-				blockEnd = len(content)
-			}
-			copy(Disk[file.DirectBlock3][:], content[BLOCK_SIZE*block:blockEnd])
-		} else {
-			indirectBlockVal := getIndirectBlock(file)
-			for indirectBlockNum, blockLoc := range indirectBlockVal {
-				blockEnd := BLOCK_SIZE * (block + 1)
-				if blockEnd > len(content) {
-					blockEnd = len(content)
-				}
-				if blockLoc != 0 {
-					copy(Disk[blockLoc][:], content[BLOCK_SIZE*block:BLOCK_SIZE*(block+1)])
-					block++
-					if block >= numCompleteBlocks {
-						break
-					}
-				} else {
-					newBlock := allocateNewBlock(ReadSuperBlock())
-					indirectBlockVal[indirectBlockNum] = newBlock
-					//write the actual data to disk
-					copy(Disk[newBlock][:], content[BLOCK_SIZE*block:blockEnd])
-					block++
-				}
-			}
-			indirectBlockBytes := EncodeToBytes(indirectBlockVal)
-			copy(Disk[file.IndirectBlock][:], indirectBlockBytes)
+// readAt fills p with the file's bytes starting at off, it stops early at the end of the file
+func (fsys *FileSys) readAt(file *INode, p []byte, off int64) (int, error) {
+	if off >= int64(file.Size) {
+		return 0, io.EOF
+	}
+	wanted := len(p)
+	if remaining := int64(file.Size) - off; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	blockSize := int64(fsys.blockSize())
+	bytesRead := 0
+	for bytesRead < len(p) {
+		pos := off + int64(bytesRead)
+		blockOffset := int(pos % blockSize)
+		blockNum, err := fsys.blockForIndex(file, int(pos/blockSize), false)
+		if err != nil {
+			return bytesRead, err
 		}
-	}
-	if hasLeftovers {
-		leftovers := content[(len(content)/BLOCK_SIZE)*block:]
-		if numCompleteBlocks == 0 {
-			if file.DirectBlock1 == 0 {
-				file.DirectBlock1 = allocateNewBlock(ReadSuperBlock())
-			}
-			copy(Disk[file.DirectBlock1][:], leftovers)
-		} else if numCompleteBlocks == 1 {
-			copy(Disk[file.DirectBlock2][:], leftovers)
-		} else if numCompleteBlocks == 2 {
-			copy(Disk[file.DirectBlock3][:], leftovers)
-		} else {
-			indirectBlockVal := getIndirectBlock(file)
-			finalBlockLoc := indirectBlockVal[numCompleteBlocks-3] 
-			if finalBlockLoc != 0 {
-				copy(Disk[finalBlockLoc][:], leftovers)
-			} else {
-				newBlock := allocateNewBlock(ReadSuperBlock())
-				indirectBlockVal[numCompleteBlocks-3] = newBlock
-				indirectBlockBytes := EncodeToBytes(indirectBlockVal)
-				//write the indirect block to disk
-				copy(Disk[file.IndirectBlock][:], indirectBlockBytes)
-				//write the actual data to disk
-				copy(Disk[newBlock][:], leftovers)
+		blockData := make([]byte, blockSize) //a block that was never written reads back as zeros
+		if blockNum != 0 {
+			if blockData, err = fsys.readBlock(blockNum); err != nil {
+				return bytesRead, err
 			}
 		}
+		bytesRead += copy(p[bytesRead:], blockData[blockOffset:])
 	}
-	writeInodeToDisk(file, inodeNum, ReadSuperBlock())
+	if bytesRead < wanted {
+		return bytesRead, io.EOF
+	}
+	return bytesRead, nil
 }
 
-// returns location of newly allocated block
-func allocateNewBlock(sblock SuperBlock) int {
-	freeBlockBitmap := ReadFreeBlockBitmap(sblock)
-	blockNum := RootFolder.DirectBlock1 
-	for bitblock, bitmapBlock := range freeBlockBitmap {
-		for locInBlock, bit := range bitmapBlock {
-			if bitblock == 0 && locInBlock <= blockNum {
-				continue 
+// writeAt puts p into the file starting at off, allocating blocks as needed, and grows the file if it ends up longer.
+// The inode is written back to disk when it's done
+func (fsys *FileSys) writeAt(file *INode, inodeNum int, p []byte, off int64) (int, error) {
+	var tooLarge error
+	maxSize := fsys.maxFileSize(file)
+	if off >= maxSize {
+		//nothing fits, so leave the inode alone. Its size couldn't be stored past maxSize anyway
+		return 0, fmt.Errorf("writing at %d, past %d bytes: %w", off, maxSize, ErrFileTooLarge)
+	}
+	if off+int64(len(p)) > maxSize {
+		//write whatever fits and then complain, like a real disk does
+		tooLarge = fmt.Errorf("writing past %d bytes: %w", maxSize, ErrFileTooLarge)
+		p = p[:maxSize-off]
+	}
+	var err error
+	file.LastModifyTime = time.Now().Unix() //update last modify time
+	blockSize := fsys.blockSize()
+	lastBlock := int((off + int64(len(p)) + int64(blockSize) - 1) / int64(blockSize))
+	reservedTo := 0
+	bytesWritten := 0
+	for bytesWritten < len(p) {
+		pos := off + int64(bytesWritten)
+		blockOffset := int(pos % int64(blockSize))
+		blockIndex := int(pos / int64(blockSize))
+		if blockIndex >= reservedTo {
+			//let the mapper get lots of blocks at once if it wants to, an indirect block's worth so
+			//the blocks it touches still fit in the journal. Running out of space isn't a problem
+			//yet, the loop writes whatever fits and then reports it
+			reservedTo = min(blockIndex+fsys.pointersPerBlock(), lastBlock)
+			if err = fsys.mapperFor(file).reserve(file, blockIndex, reservedTo); err != nil && !errors.Is(err, ErrNoSpace) {
+				break
 			}
-			if !bit {
-				//this bit is available
-				freeBlockBitmap[bitblock][locInBlock] = true
-				writeFreeBlockBitmapToDisk(freeBlockBitmap, sblock)
-				return locInBlock
-			} else {
-
+		}
+		var blockNum int
+		blockNum, err = fsys.blockForIndex(file, blockIndex, true)
+		if err != nil {
+			break
+		}
+		blockData := make([]byte, blockSize)
+		chunk := len(p) - bytesWritten
+		if chunk > blockSize-blockOffset {
+			chunk = blockSize - blockOffset
+		}
+		if chunk < blockSize {
+			//only part of the block changes, so keep whatever else is in it
+			if blockData, err = fsys.readBlock(blockNum); err != nil {
+				break
 			}
 		}
+		copy(blockData[blockOffset:], p[bytesWritten:bytesWritten+chunk])
+		if err = fsys.writeDataBlock(blockNum, blockData); err != nil {
+			break
+		}
+		bytesWritten += chunk
+		//a big write is stored a piece at a time, so it never needs more journal than there is
+		if err = fsys.checkpoint(func() error { return fsys.saveWrite(file, inodeNum, off+int64(bytesWritten)) }); err != nil {
+			break
+		}
+	}
+	//the size only grows to cover bytes that really got written, a write of nothing (or one that
+	//failed straight away) doesn't stretch the file out to off
+	end := int64(file.Size)
+	if bytesWritten > 0 {
+		end = max(end, off+int64(bytesWritten))
+	}
+	if err != nil {
+		//give back whatever got claimed for the bytes that never made it
+		if freeErr := fsys.freeBlocksFrom(file, int((end+int64(blockSize)-1)/int64(blockSize))); freeErr != nil {
+			err = freeErr
+		}
+	}
+	if saveErr := fsys.saveWrite(file, inodeNum, end); saveErr != nil {
+		return bytesWritten, saveErr
 	}
-	log.Fatal("Unable to allocate a free block")
-	return 0
+	if err == nil {
+		err = tooLarge
+	}
+	return bytesWritten, err
 }
 
-func getIndirectBlock(file *INode) IndirectBlock {
-	if file.IndirectBlock == 0 {
-		file.IndirectBlock = allocateNewBlock(ReadSuperBlock())
-		return IndirectBlock{}
+// saveWrite writes the inode back after a write that got as far as end, growing the file to end
+// if it was shorter
+func (fsys *FileSys) saveWrite(file *INode, inodeNum int, end int64) error {
+	if end > int64(file.Size) {
+		file.Size = int(end)
 	}
-	//now we need to do the indirect blocks
-	indirectBlockBytes := getIndirectBlockFromDisk(file.IndirectBlock)
-	indirectBlockVal := IndirectBlock{}
-	decoder := gob.NewDecoder(bytes.NewReader(indirectBlockBytes[:]))
-	err := decoder.Decode(&indirectBlockVal)
-	if err != nil { 
-		log.Fatal("Error decoding IndirectBlock from disk - better blue Screen", err)
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return err
 	}
-	return indirectBlockVal
+	return fsys.writeInodeToDisk(file, inodeNum, sblock)
 }
 
-func getIndirectBlockFromDisk(indirectBlockNum int) [1024]byte {
-	return Disk[indirectBlockNum]
+// Read hands back the whole file as a string, use a File from Open to read just part of it
+func (fsys *FileSys) Read(file *INode) (string, error) {
+	if !file.IsValid {
+		return "", ErrNotFound
+	}
+	if file.IsDirectory {
+		return "", ErrIsDir
+	}
+	fileContents := make([]byte, file.Size)
+	if _, err := fsys.readAt(file, fileContents, 0); err != nil && err != io.EOF {
+		return "", err
+	}
+	return string(fileContents), nil
 }
 
-func DecodeDirectoryBlock(blockNum int) (DirectoryBlock, error) {
-	if blockNum < 0 || blockNum >= len(Disk) {
-		return DirectoryBlock{}, fmt.Errorf("block number %d out of range", blockNum)
-	}
+// Write replaces the file's contents with content, starting from byte 0. The blocks the file
+// already has get written over and any it no longer needs are freed
+func (fsys *FileSys) Write(file *INode, inodeNum int, content []byte) (err error) {
+	fsys.begin()
+	defer func() { err = fsys.commit(err) }()
 
-	blockData := Disk[blockNum][:]
-	var dirBlock DirectoryBlock
-	decoder := gob.NewDecoder(bytes.NewReader(blockData))
-	if err := decoder.Decode(&dirBlock); err != nil {
-		return DirectoryBlock{}, fmt.Errorf("error decoding directory block: %w", err)
+	if _, err := fsys.writeAt(file, inodeNum, content, 0); err != nil {
+		return err
 	}
-
-	return dirBlock, nil
+	return fsys.truncate(file, inodeNum, len(content))
 }
-func GetINodeDetails(path string) (inode INode, inodeNum int, err error) {
-	parts := strings.Split(strings.Trim(path, "/"), "/")
-	if len(parts) == 0 {
-		return INode{}, 0, fmt.Errorf("invalid path")
-	}
 
-	
-	inode = INode{IsValid: true, IsDirectory: false, DirectBlock1: 1} // Simulated inode data
-	inodeNum = 1                                                      // Simulated inode number
+// Truncate changes the size of the file with inode number inodeNum. Shrinking frees the blocks
+// past the new end, growing leaves a hole that reads back as zeros
+func (fsys *FileSys) Truncate(inodeNum int, size int) (err error) {
+	fsys.begin()
+	defer func() { err = fsys.commit(err) }()
 
-	return inode, inodeNum, nil
+	file, err := fsys.getInodeFromDisk(inodeNum)
+	if err != nil {
+		return err
+	}
+	if !file.IsValid {
+		return fmt.Errorf("truncate inode %d: %w", inodeNum, ErrNotFound)
+	}
+	if file.IsDirectory {
+		return fmt.Errorf("truncate inode %d: %w", inodeNum, ErrIsDir)
+	}
+	return fsys.truncate(&file, inodeNum, size)
 }
 
-func FindSubdirectories(dir string) (INode, int) {
-	stringSlice := strings.Split(dir, "/")
-	parentNode := RootFolder
-	parentNodeNum := 0
-	for _, str := range stringSlice {
-		parentNode, parentNodeNum = Open(READ, str, parentNode)
-		if parentNodeNum == 0 {
-			log.Fatal("Location not found")
-			return INode{}, 0
+func (fsys *FileSys) truncate(file *INode, inodeNum int, size int) error {
+	if size < 0 {
+		return fmt.Errorf("truncate to %d: %w", size, ErrInvalid)
+	}
+	if int64(size) > fsys.maxFileSize(file) {
+		return fmt.Errorf("truncate to %d: %w", size, ErrFileTooLarge)
+	}
+	if size < file.Size {
+		blockSize := fsys.blockSize()
+		keepBlocks := (size + blockSize - 1) / blockSize
+		if err := fsys.shrinkFile(file, inodeNum, keepBlocks); err != nil {
+			return err
+		}
+		//zero the rest of the new last block, otherwise growing the file again would bring the old bytes back
+		if tail := size % blockSize; tail != 0 {
+			blockNum, err := fsys.blockForIndex(file, size/blockSize, false)
+			if err != nil {
+				return err
+			}
+			if blockNum != 0 {
+				blockData, err := fsys.readBlock(blockNum)
+				if err != nil {
+					return err
+				}
+				clear(blockData[tail:])
+				if err := fsys.writeBlock(blockNum, blockData); err != nil {
+					return err
+				}
+			}
 		}
 	}
-	return parentNode, parentNodeNum
+	file.Size = size
+	file.LastModifyTime = time.Now().Unix()
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return err
+	}
+	return fsys.writeInodeToDisk(file, inodeNum, sblock)
 }
-func GetInodeFromPath(path string) (*INode, error) {
-	fmt.Println("Path:", path) // Print the path
-	if path == "" {
-		return RootInode, nil
+
+// SetTimes changes a file's creation and last modify times, cp -p uses it to keep the originals
+func (fsys *FileSys) SetTimes(inodeNum int, createTime int64, modifyTime int64) (err error) {
+	fsys.begin()
+	defer func() { err = fsys.commit(err) }()
+
+	inode, err := fsys.getInodeFromDisk(inodeNum)
+	if err != nil {
+		return err
 	}
-	parts := strings.Split(strings.Trim(path, "/"), "/")
-	if len(parts) == 0 {
-		return nil, errors.New("invalid path")
+	if !inode.IsValid {
+		return fmt.Errorf("inode %d: %w", inodeNum, ErrNotFound)
 	}
+	inode.CreateTime = createTime
+	inode.LastModifyTime = modifyTime
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return err
+	}
+	return fsys.writeInodeToDisk(&inode, inodeNum, sblock)
+}
 
-	// Start at the root directory
-	currentInode := RootInode
-
-	// Traverse the path
-	for _, part := range parts {
-		fmt.Println("Part:", part) // Print the part
-		if !currentInode.IsDirectory {
-			return nil, errors.New("not a directory: " + part)
+// freeBlock gives blocks back to the free block bitmap, it is the opposite of allocateNewBlock
+func (fsys *FileSys) freeBlock(blockNums ...int) error {
+	if len(blockNums) == 0 {
+		return nil
+	}
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return err
+	}
+	freeBlockBitmap, err := fsys.ReadFreeBlockBitmap(sblock)
+	if err != nil {
+		return err
+	}
+	for _, blockNum := range blockNums {
+		if blockNum < sblock.DataBlockStart || blockNum >= sblock.TotalBlocks {
+			return fmt.Errorf("%w: can't free block %d, it isn't a data block", ErrCorrupt, blockNum)
 		}
-
-		// Look up the next part in the current directory
-		nextInode, ok := currentInode.Children[part]
-		if !ok {
-			return nil, errors.New("no such file or directory: " + part)
+		freeBlockBitmap.Clear(blockNum)
+		if fsys.tx != nil {
+			fsys.tx.freed[blockNum] = true
 		}
+	}
+	fsys.forgetFreeBlocks()
+	return fsys.writeBitmapBlocks(sblock.FreeBlockStart, freeBlockBitmap, blockNums) //only these bits changed
+}
 
-		currentInode = nextInode
+func (fsys *FileSys) DecodeDirectoryBlock(blockNum int) (DirectoryBlock, error) {
+	if blockNum < 0 || blockNum >= fsys.dev.NumBlocks() {
+		return nil, fmt.Errorf("%w: block number %d out of range", ErrCorrupt, blockNum)
 	}
 
-	return currentInode, nil
+	blockData, err := fsys.readBlock(blockNum)
+	if err != nil {
+		return nil, err
+	}
+	var dirBlock DirectoryBlock
+	if err := dirBlock.UnmarshalBinary(blockData); err != nil {
+		return nil, fmt.Errorf("directory block %d: %w", blockNum, err)
+	}
+
+	return dirBlock, nil
 }
@@ -0,0 +1,66 @@
+package FileSystem
+
+import "testing"
+
+// newTestDisk formats a disk of numBlocks 1024 byte blocks in memory
+func newTestDisk(t *testing.T, numBlocks int, opts FormatOptions) *FileSys {
+	t.Helper()
+	fsys := New(NewMemoryDevice(numBlocks, 1024))
+	if err := fsys.Format(opts); err != nil {
+		t.Fatalf("format: %v", err)
+	}
+	return fsys
+}
+
+// createFile makes the file at path holding size bytes and returns its inode number
+func createFile(t *testing.T, fsys *FileSys, path string, size int) int {
+	t.Helper()
+	parent, _, name, err := fsys.lookupParent(path)
+	if err != nil {
+		t.Fatalf("create %s: %v", path, err)
+	}
+	file, err := fsys.Open(CREATE|WRITE, name, parent)
+	if err != nil {
+		t.Fatalf("create %s: %v", path, err)
+	}
+	defer file.Close()
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	if _, err := file.Write(data); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+	return file.InodeNum()
+}
+
+// openFile opens the file at path for reading and writing
+func openFile(t *testing.T, fsys *FileSys, path string) *File {
+	t.Helper()
+	parent, _, name, err := fsys.lookupParent(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	file, err := fsys.Open(READ|WRITE, name, parent)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	return file
+}
+
+func TestEmptyWriteKeepsSize(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	inodeNum := createFile(t, fsys, "f", 0)
+	file := openFile(t, fsys, "f")
+	if _, err := file.WriteAt(nil, 5000); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	inode, err := fsys.getInodeFromDisk(inodeNum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inode.Size != 0 {
+		t.Fatalf("an empty write made the file %d bytes", inode.Size)
+	}
+}
@@ -0,0 +1,126 @@
+package FileSystem
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestOpenModes(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	createFile(t, fsys, "f", 10)
+	tests := []struct {
+		name string
+		mode int
+		want error
+	}{
+		{"f", READ, nil},
+		{"f", WRITE, nil},
+		{"f", APPEND, nil},
+		{"f", READ | WRITE | TRUNC, nil},
+		{"f", CREATE, nil},
+		{"f", CREATE | EXCL, ErrExist},
+		{"f", READ | TRUNC, ErrBadMode},
+		{"missing", READ, ErrNotFound},
+		{"missing", WRITE, ErrNotFound},
+		{"new", CREATE | EXCL, nil},
+	}
+	for _, test := range tests {
+		file, err := fsys.Open(test.mode, test.name, fsys.RootFolder)
+		if !errors.Is(err, test.want) {
+			t.Errorf("open %s with mode %b: got %v, want %v", test.name, test.mode, err, test.want)
+		}
+		if err == nil {
+			file.Close()
+		}
+	}
+}
+
+func TestOpenModeLimitsHandle(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	createFile(t, fsys, "f", 10)
+	readOnly, err := fsys.Open(READ, "f", fsys.RootFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer readOnly.Close()
+	if _, err := readOnly.Write([]byte("x")); !errors.Is(err, ErrBadMode) {
+		t.Fatalf("write through a READ handle: got %v, want ErrBadMode", err)
+	}
+	writeOnly, err := fsys.Open(WRITE, "f", fsys.RootFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writeOnly.Close()
+	if _, err := writeOnly.Read(make([]byte, 1)); !errors.Is(err, ErrBadMode) {
+		t.Fatalf("read through a WRITE handle: got %v, want ErrBadMode", err)
+	}
+}
+
+func TestOpenAppendAndTrunc(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	createFile(t, fsys, "f", 0)
+	appender, err := fsys.Open(APPEND, "f", fsys.RootFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer appender.Close()
+	for _, part := range []string{"ab", "cd"} {
+		if _, err := appender.Seek(0, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := appender.Write([]byte(part)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := appender.WriteAt([]byte("x"), 0); !errors.Is(err, ErrBadMode) {
+		t.Fatalf("WriteAt through an APPEND handle: got %v, want ErrBadMode", err)
+	}
+	file := openFile(t, fsys, "f")
+	content, err := io.ReadAll(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "abcd" {
+		t.Fatalf("appending ab and cd gave %q", content)
+	}
+
+	truncated, err := fsys.Open(WRITE|TRUNC, "f", fsys.RootFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated.Close()
+	if size := truncated.INode().Size; size != 0 {
+		t.Fatalf("the file is %d bytes after opening with TRUNC", size)
+	}
+}
+
+func TestTruncateGrowReadsZeros(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	createFile(t, fsys, "f", 3000)
+	file := openFile(t, fsys, "f")
+	defer file.Close()
+	//shrink into the middle of a block first, so the old bytes past the end are still on disk
+	for _, size := range []int64{1500, 5000} {
+		if err := file.Truncate(size); err != nil {
+			t.Fatalf("truncate to %d: %v", size, err)
+		}
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(content) != 5000 {
+		t.Fatalf("the file is %d bytes after growing it to 5000", len(content))
+	}
+	for i, b := range content {
+		want := byte(0)
+		if i < 1500 {
+			want = byte(i)
+		}
+		if b != want {
+			t.Fatalf("byte %d is %d, want %d", i, b, want)
+		}
+	}
+}
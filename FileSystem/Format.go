@@ -0,0 +1,174 @@
+package FileSystem
+
+import (
+	"fmt"
+	"math"
+	"os"
+)
+
+// FormatOptions picks the shape of a new filesystem. Anything left at zero gets the default
+// (NUM_BLOCKS blocks of BLOCK_SIZE bytes, NUM_INODES inodes, block pointers and JOURNAL_BLOCKS
+// blocks of journal)
+type FormatOptions struct {
+	TotalSize     int64 //bytes on the disk, rounded down to whole blocks
+	BlockSize     int   //bytes per block, a power of 2 from 512 to 65536
+	NumInodes     int   //how many files and folders the disk can hold, including the root folder
+	InodeFormat   int   //INODE_BLOCKS or INODE_EXTENTS
+	JournalBlocks int   //blocks set aside for the journal, including its header
+}
+
+const (
+	MIN_BLOCK_SIZE = 512 //a block has to at least fit the superblock and a few directory entries
+	MAX_BLOCK_SIZE = 65536
+)
+
+// Inode formats, how files keep track of their blocks (see BlockMap.go)
+const (
+	INODE_BLOCKS  = iota //direct, indirect, double and triple indirect block pointers
+	INODE_EXTENTS        //runs of blocks in a row, better for big files written in one go
+)
+
+// withDefaults fills in every option that was left at zero
+func (opts FormatOptions) withDefaults() FormatOptions {
+	if opts.BlockSize == 0 {
+		opts.BlockSize = BLOCK_SIZE
+	}
+	if opts.TotalSize == 0 {
+		opts.TotalSize = int64(NUM_BLOCKS) * int64(opts.BlockSize)
+	}
+	if opts.NumInodes == 0 {
+		opts.NumInodes = NUM_INODES
+	}
+	if opts.JournalBlocks == 0 {
+		//smaller blocks make for a header that lists fewer blocks, there's no use in more journal than that
+		opts.JournalBlocks = min(JOURNAL_BLOCKS, 1+journalHeaderCapacity(opts.BlockSize))
+	}
+	return opts
+}
+
+// layout works out where everything goes on the disk and returns the superblock describing it.
+// Order on the disk is the superblock in block 0, the inode bitmap from block 1, then the free
+// block bitmap, the inodes, the journal and the datablocks after that
+func (opts FormatOptions) layout() (SuperBlock, error) {
+	opts = opts.withDefaults()
+	blockSize := opts.BlockSize
+	if blockSize < MIN_BLOCK_SIZE || blockSize > MAX_BLOCK_SIZE || blockSize&(blockSize-1) != 0 {
+		return SuperBlock{}, fmt.Errorf("%w: block size %d must be a power of 2 from %d to %d", ErrInvalid, blockSize, MIN_BLOCK_SIZE, MAX_BLOCK_SIZE)
+	}
+	if opts.InodeFormat != INODE_BLOCKS && opts.InodeFormat != INODE_EXTENTS {
+		return SuperBlock{}, fmt.Errorf("%w: unknown inode format %d", ErrInvalid, opts.InodeFormat)
+	}
+	if opts.NumInodes < 2 { //inode 0 is never used and inode 1 is the root folder
+		return SuperBlock{}, fmt.Errorf("%w: %d inodes is not enough for the root folder", ErrInvalid, opts.NumInodes)
+	}
+	if opts.JournalBlocks < MIN_JOURNAL_BLOCKS {
+		return SuperBlock{}, fmt.Errorf("%w: a journal needs at least %d blocks", ErrInvalid, MIN_JOURNAL_BLOCKS)
+	}
+	if most := 1 + journalHeaderCapacity(blockSize); opts.JournalBlocks > most {
+		return SuperBlock{}, fmt.Errorf("%w: a journal header of %d bytes only lists enough blocks for a journal of %d", ErrInvalid, blockSize, most)
+	}
+	if opts.TotalSize < 0 || opts.TotalSize/int64(blockSize) > math.MaxUint32 {
+		return SuperBlock{}, fmt.Errorf("%w: disk size %d is out of range", ErrInvalid, opts.TotalSize)
+	}
+	totalBlocks := int(opts.TotalSize / int64(blockSize))
+	freeBlockStart := 1 + bitmapBlocks(opts.NumInodes, blockSize)
+	inodeStart := freeBlockStart + bitmapBlocks(totalBlocks, blockSize)
+	journalStart := inodeStart + (opts.NumInodes*INODE_SIZE+blockSize-1)/blockSize
+	dataBlockStart := journalStart + opts.JournalBlocks
+	if totalBlocks <= dataBlockStart {
+		return SuperBlock{}, fmt.Errorf("%w: a disk of %d blocks has no room for data blocks after %d inodes and %d blocks of journal", ErrNoSpace, totalBlocks, opts.NumInodes, opts.JournalBlocks)
+	}
+	return SuperBlock{
+		INodeStart:       inodeStart,
+		RootDirInode:     1,
+		FreeBlockStart:   freeBlockStart,
+		InodeBitmapStart: 1,
+		DataBlockStart:   dataBlockStart,
+		TotalBlocks:      totalBlocks,
+		BlockSize:        blockSize,
+		InodeSize:        INODE_SIZE,
+		NumInodes:        opts.NumInodes,
+		InodeFormat:      opts.InodeFormat,
+		JournalStart:     journalStart,
+		JournalBlocks:    opts.JournalBlocks,
+	}, nil
+}
+
+// Format wipes the device and lays a new, empty filesystem out on it. The block size has to be
+// the device's and the disk can't be bigger than the device (the rest of a bigger device is left unused)
+func (fsys *FileSys) Format(opts FormatOptions) error {
+	if opts.BlockSize == 0 {
+		opts.BlockSize = fsys.blockSize()
+	}
+	if opts.TotalSize == 0 {
+		opts.TotalSize = int64(fsys.dev.NumBlocks()) * int64(fsys.blockSize())
+	}
+	if opts.BlockSize != fsys.blockSize() {
+		return fmt.Errorf("%w: block size %d doesn't match the device's %d", ErrInvalid, opts.BlockSize, fsys.blockSize())
+	}
+	if opts.TotalSize/int64(opts.BlockSize) > int64(fsys.dev.NumBlocks()) { //only whole blocks count
+		return fmt.Errorf("%w: disk size %d is bigger than the device", ErrInvalid, opts.TotalSize)
+	}
+	supBlock, err := opts.layout()
+	if err != nil {
+		return err
+	}
+
+	fsys.sblock = nil //whatever we had cached is about to be wiped
+	fsys.cwd = 0
+	fsys.nextFit = 0
+	fsys.forgetFreeBlocks()
+	//explicitly zero the filesystem - this shouldn't be needed
+	emptyBlock := make([]byte, supBlock.BlockSize)
+	for blockLoc := 0; blockLoc < supBlock.TotalBlocks; blockLoc++ {
+		if err := fsys.writeBlock(blockLoc, emptyBlock); err != nil {
+			return err
+		}
+	}
+
+	superblockBytes, err := supBlock.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := fsys.writeBlock(0, superblockBytes); err != nil {
+		return err
+	}
+	fsys.sblock = &supBlock
+	if err := fsys.createInodeBitmap(supBlock); err != nil {
+		return err
+	}
+	if err := fsys.createFreeBlockBitmap(supBlock); err != nil {
+		return err
+	}
+	if err := fsys.createInodes(supBlock); err != nil {
+		return err
+	}
+	return fsys.createRootDir(supBlock)
+}
+
+// FormatImage makes a fresh disk image at path laid out from opts, throwing away anything
+// that was in it before, and returns it mounted
+func FormatImage(path string, opts FormatOptions) (*FileSys, error) {
+	opts = opts.withDefaults()
+	supBlock, err := opts.layout()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Truncate(path, 0); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("unable to clear disk image: %w", err)
+	}
+	dev, err := OpenFileDevice(path, supBlock.TotalBlocks, supBlock.BlockSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open disk image: %w", err)
+	}
+	fsys := New(dev)
+	if err := fsys.Format(opts); err != nil {
+		dev.Close()
+		return nil, err
+	}
+	if err := fsys.Flush(); err != nil {
+		dev.Close()
+		return nil, err
+	}
+	return fsys, nil
+}
@@ -0,0 +1,69 @@
+package FileSystem
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestFormatOptions(t *testing.T) {
+	fsys := New(NewMemoryDevice(4000, 512))
+	if err := fsys.Format(FormatOptions{TotalSize: 3000 * 512, NumInodes: 4}); err != nil {
+		t.Fatal(err)
+	}
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sblock.TotalBlocks != 3000 || sblock.BlockSize != 512 || sblock.NumInodes != 4 {
+		t.Fatalf("formatted %d blocks of %d bytes with %d inodes, want 3000 of 512 with 4", sblock.TotalBlocks, sblock.BlockSize, sblock.NumInodes)
+	}
+	//inode 0 is never used and inode 1 is the root folder, so only two more files fit
+	createFile(t, fsys, "/a", 2000)
+	createFile(t, fsys, "/b", 10)
+	parent, _, name, err := fsys.lookupParent("/c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.Open(CREATE|WRITE, name, parent); !errors.Is(err, ErrNoInodes) {
+		t.Fatalf("creating a file with every inode in use: got %v, want ErrNoInodes", err)
+	}
+	file := openFile(t, fsys, "/a")
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(content) != 2000 {
+		t.Fatalf("a 2000 byte file on 512 byte blocks reads back as %d bytes", len(content))
+	}
+}
+
+func TestFormatRejects(t *testing.T) {
+	fsys := New(NewMemoryDevice(3000, 1024))
+	tests := []struct {
+		name string
+		opts FormatOptions
+		want error
+	}{
+		{"a block size that isn't the device's", FormatOptions{BlockSize: 512}, ErrInvalid},
+		{"a block size that isn't a power of 2", FormatOptions{BlockSize: 1000}, ErrInvalid},
+		{"too few inodes", FormatOptions{NumInodes: 1}, ErrInvalid},
+		{"no room for data blocks", FormatOptions{TotalSize: 4 * 1024}, ErrNoSpace},
+	}
+	for _, test := range tests {
+		if err := fsys.Format(test.opts); !errors.Is(err, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestFormatRoundsSizeDown(t *testing.T) {
+	fsys := New(NewMemoryDevice(1000001/1024, 1024))
+	if err := fsys.Format(FormatOptions{TotalSize: 1000001}); err != nil {
+		t.Fatalf("format with a size that isn't whole blocks: %v", err)
+	}
+	if err := fsys.Format(FormatOptions{TotalSize: 1000001 + 1024}); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("format bigger than the device: got %v, want ErrInvalid", err)
+	}
+}
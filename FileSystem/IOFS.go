@@ -0,0 +1,201 @@
+package FileSystem
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+// IOFS is a read only view of a FileSys for standard library code that wants an fs.FS
+// (fs.WalkDir, template.ParseFS, http.FS, ...). Paths are always relative to the root folder.
+type IOFS struct {
+	fsys *FileSys
+}
+
+var (
+	_ fs.FS         = (*IOFS)(nil)
+	_ fs.ReadDirFS  = (*IOFS)(nil)
+	_ fs.StatFS     = (*IOFS)(nil)
+	_ fs.ReadFileFS = (*IOFS)(nil)
+)
+
+// FS returns the io/fs view of the filesystem
+func (fsys *FileSys) FS() *IOFS {
+	return &IOFS{fsys: fsys}
+}
+
+// resolve looks name up starting from the root folder, whatever the current folder is
+func (ifs *IOFS) resolve(op string, name string) (INode, int, error) {
+	if !fs.ValidPath(name) {
+		return INode{}, 0, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	inode, inodeNum, err := ifs.fsys.Lookup("/" + name)
+	if err != nil {
+		return INode{}, 0, toPathError(op, name, err)
+	}
+	return inode, inodeNum, nil
+}
+
+// toPathError translates our errors into the ones io/fs users check for
+func toPathError(op string, name string, err error) error {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		err = fs.ErrNotExist
+	case errors.Is(err, ErrNotDir), errors.Is(err, ErrIsDir), errors.Is(err, ErrInvalid):
+		err = fs.ErrInvalid
+	case errors.Is(err, ErrExist):
+		err = fs.ErrExist
+	case errors.Is(err, ErrBadMode):
+		err = fs.ErrPermission
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func (ifs *IOFS) Open(name string) (fs.File, error) {
+	inode, inodeNum, err := ifs.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	info := ifs.fsys.fileInfo(baseName(name), inode)
+	if inode.IsDirectory {
+		entries, err := ifs.readDir(inode)
+		if err != nil {
+			return nil, toPathError("open", name, err)
+		}
+		return &ioDir{info: info, entries: entries}, nil
+	}
+	file := &File{fsys: ifs.fsys, inode: inode, inodeNum: inodeNum, mode: READ}
+	return &ioFile{info: info, file: file}, nil
+}
+
+func (ifs *IOFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	inode, _, err := ifs.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !inode.IsDirectory {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, err := ifs.readDir(inode)
+	if err != nil {
+		return nil, toPathError("readdir", name, err)
+	}
+	return entries, nil
+}
+
+func (ifs *IOFS) Stat(name string) (fs.FileInfo, error) {
+	inode, _, err := ifs.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return ifs.fsys.fileInfo(baseName(name), inode), nil
+}
+
+func (ifs *IOFS) ReadFile(name string) ([]byte, error) {
+	inode, _, err := ifs.resolve("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ifs.fsys.Read(&inode)
+	if err != nil {
+		return nil, toPathError("readfile", name, err)
+	}
+	return []byte(content), nil
+}
+
+// readDir lists a folder sorted by name, leaving out . and .. like fs.ReadDir does
+func (ifs *IOFS) readDir(dir INode) ([]fs.DirEntry, error) {
+	dirEntries, err := ifs.fsys.readDirectoryEntries(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, 0, len(dirEntries))
+	for _, entry := range dirEntries {
+		name := entryName(entry)
+		if name == "." || name == ".." {
+			continue
+		}
+		inode, err := ifs.fsys.getInodeFromDisk(entry.Inode)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(ifs.fsys.fileInfo(name, inode)))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func baseName(name string) string {
+	if name == "." {
+		return "/"
+	}
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// fileInfo fills in an fs.FileInfo from the inode
+func (fsys *FileSys) fileInfo(name string, inode INode) fs.FileInfo {
+	return &ioFileInfo{name: name, inode: inode}
+}
+
+type ioFileInfo struct {
+	name  string
+	inode INode
+}
+
+func (info *ioFileInfo) Name() string { return info.name }
+func (info *ioFileInfo) Size() int64  { return int64(info.inode.Size) }
+func (info *ioFileInfo) Mode() fs.FileMode {
+	if info.inode.IsDirectory {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
+func (info *ioFileInfo) ModTime() time.Time { return time.Unix(info.inode.LastModifyTime, 0) }
+func (info *ioFileInfo) IsDir() bool        { return info.inode.IsDirectory }
+func (info *ioFileInfo) Sys() any           { return info.inode }
+
+// ioFile is an open regular file, reads go straight through to the File handle
+type ioFile struct {
+	info fs.FileInfo
+	file *File
+}
+
+func (file *ioFile) Stat() (fs.FileInfo, error) { return file.info, nil }
+func (file *ioFile) Read(p []byte) (int, error) { return file.file.Read(p) }
+func (file *ioFile) Seek(offset int64, whence int) (int64, error) {
+	return file.file.Seek(offset, whence)
+}
+func (file *ioFile) ReadAt(p []byte, off int64) (int, error) { return file.file.ReadAt(p, off) }
+func (file *ioFile) Close() error                            { return file.file.Close() }
+
+// ioDir is an open folder, ReadDir hands out its entries a few at a time
+type ioDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (dir *ioDir) Stat() (fs.FileInfo, error) { return dir.info, nil }
+func (dir *ioDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: dir.info.Name(), Err: fs.ErrInvalid}
+}
+func (dir *ioDir) Close() error { return nil }
+
+func (dir *ioDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	remaining := dir.entries[dir.offset:]
+	if count <= 0 {
+		dir.offset = len(dir.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+	dir.offset += count
+	return remaining[:count], nil
+}
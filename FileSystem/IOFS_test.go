@@ -0,0 +1,15 @@
+package FileSystem
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestIOFS(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	createFile(t, fsys, "a.txt", 10)
+	createFile(t, fsys, "b.txt", 3000)
+	if err := fstest.TestFS(fsys.FS(), "a.txt", "b.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,102 @@
+package FileSystem
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// Mount opens the disk image at path as a file backed FileSys. If the image doesn't exist yet
+// (or is empty) it gets formatted with the default FormatOptions, so the caller always ends
+// up with a usable filesystem. Otherwise the block size and number of blocks come from the
+// superblock at the start of the image. Anything left in the journal is replayed first.
+func Mount(path string) (*FileSys, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && info.Size() == 0) {
+		return FormatImage(path, FormatOptions{})
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read disk image: %w", err)
+	}
+	sblock, err := peekSuperBlock(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	dev, err := OpenFileDevice(path, sblock.TotalBlocks, sblock.BlockSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open disk image: %w", err)
+	}
+	fsys := New(dev)
+	if _, err := fsys.ReadSuperBlock(); err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	//finish off whatever operation was cut short last time before anything reads the disk
+	if err := fsys.replayJournal(); err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if fsys.RootFolder, err = fsys.getInodeFromDisk(sblock.RootDirInode); err != nil {
+		dev.Close()
+		return nil, err
+	}
+	return fsys, nil
+}
+
+// peekSuperBlock decodes the superblock straight from the image file, before we know how big
+// its blocks are
+func peekSuperBlock(path string) (SuperBlock, error) {
+	var sblock SuperBlock
+	file, err := os.Open(path)
+	if err != nil {
+		return sblock, err
+	}
+	defer file.Close()
+	buf := make([]byte, SUPERBLOCK_SIZE)
+	if _, err := io.ReadFull(file, buf); err != nil {
+		return sblock, fmt.Errorf("%w: image is too small to hold a superblock", ErrCorrupt)
+	}
+	if err := sblock.UnmarshalBinary(buf); err != nil {
+		return sblock, err
+	}
+	if sblock.BlockSize < MIN_BLOCK_SIZE || sblock.BlockSize > MAX_BLOCK_SIZE || sblock.TotalBlocks <= 0 {
+		return sblock, fmt.Errorf("%w: superblock says %d blocks of %d bytes", ErrCorrupt, sblock.TotalBlocks, sblock.BlockSize)
+	}
+	return sblock, nil
+}
+
+// Reformat wipes the disk and makes sure the fresh filesystem is stored. The disk keeps its size,
+// block size, number of inodes, inode format and journal size if it was formatted before
+func (fsys *FileSys) Reformat() error {
+	opts := FormatOptions{}
+	if sblock, err := fsys.ReadSuperBlock(); err == nil {
+		opts.TotalSize = int64(sblock.TotalBlocks) * int64(sblock.BlockSize)
+		opts.NumInodes = sblock.NumInodes
+		opts.InodeFormat = sblock.InodeFormat
+		opts.JournalBlocks = sblock.JournalBlocks //0 for a disk from before there was a journal, which gets the default
+	}
+	if err := fsys.Format(opts); err != nil {
+		return err
+	}
+	return fsys.Flush()
+}
+
+// Flush makes sure every block written so far is stored on the device
+func (fsys *FileSys) Flush() error {
+	if err := fsys.dev.Flush(); err != nil {
+		return fmt.Errorf("unable to flush disk: %w", err)
+	}
+	return nil
+}
+
+// Unmount flushes the disk and closes the device if it has anything to close
+func (fsys *FileSys) Unmount() error {
+	if err := fsys.Flush(); err != nil {
+		return err
+	}
+	if closer, ok := fsys.dev.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package FileSystem
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestMountRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "disk.img")
+	fsys, err := Mount(path)
+	if err != nil {
+		t.Fatalf("mount a new image: %v", err)
+	}
+	file, err := fsys.Open(CREATE, "hello.txt", fsys.RootFolder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	if err := fsys.Unmount(); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err = Mount(path)
+	if err != nil {
+		t.Fatalf("mount the image again: %v", err)
+	}
+	defer fsys.Unmount()
+	file, err = fsys.Open(READ, "hello.txt", fsys.RootFolder)
+	if err != nil {
+		t.Fatalf("hello.txt after mounting the image again: %v", err)
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "hello" {
+		t.Fatalf("hello.txt holds %q after mounting the image again", content)
+	}
+}
@@ -0,0 +1,282 @@
+package FileSystem
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// Every operation that changes the disk (Open with CREATE, Mkdir, Rename, a Write and so on) runs as
+// a transaction. Between begin and commit the blocks it writes are held in memory, and reads see
+// them there. Commit puts them in the journal first, then the journal header saying where they
+// belong, and only after that are they written home. A crash before the header is stored means the
+// operation never happened, a crash after it means Mount finds the header and writes the blocks
+// home again (replaying). Either way the disk never ends up with half an operation on it.
+//
+// One commit can only log as many blocks as the journal has room for. Operations that can get
+// bigger than that (a big Write, RemoveAll, Check) call checkpoint as they go, which commits what
+// they have done so far once the journal is getting full. A crash can then leave such an operation
+// partly done, but only ever stopped at one of its checkpoints. Anything that still doesn't fit is
+// thrown away and fails with ErrJournalFull rather than being written without the journal.
+//
+// What's inside files doesn't go through the journal (see writeDataBlock), only the bitmaps,
+// inodes, folders, indirect blocks and extent leaves do. The journal is JournalBlocks blocks after
+// the inodes: the header, then the logged blocks in the order the header lists them.
+
+const (
+	JOURNAL_BLOCKS      = 254 //default journal size including the header, the most a 1024 byte header can list
+	MIN_JOURNAL_BLOCKS  = 32  //a header and room for a couple of checkpoints' worth of blocks
+	JOURNAL_HEADER_SIZE = 12  //magic, Count and Checksum, the block numbers come after
+	JOURNAL_SLACK       = 16  //room checkpoint leaves for the next step, one block of a file can take a bitmap block, 3 indirect blocks and the inode
+)
+
+// JournalHeader is the first block of the journal. A header with no blocks means there is nothing to replay
+type JournalHeader struct {
+	Blocks   []int  //where each logged block goes, the i-th block after the header belongs in Blocks[i]
+	Checksum uint32 //crc32 of the logged blocks, so a header that got stored before them isn't replayed
+}
+
+// transaction holds the writes of the operation in progress
+type transaction struct {
+	depth  int            //operations started inside an operation (Mkdir calls Open) join the outer one
+	blocks map[int][]byte //block number to what goes there
+	order  []int          //block numbers in the order they were first written
+	freed  map[int]bool   //blocks this operation gave back to the free block bitmap
+}
+
+// hold keeps a copy of a written block until the transaction commits
+func (tx *transaction) hold(blockNum int, data []byte, blockSize int) {
+	if tx.blocks[blockNum] == nil {
+		tx.order = append(tx.order, blockNum)
+	}
+	block := make([]byte, blockSize) //anything shorter than a block gets zero padded, like on the device
+	copy(block, data)
+	tx.blocks[blockNum] = block
+}
+
+// drop forgets a held block, it has been written straight to the device since
+func (tx *transaction) drop(blockNum int) {
+	if tx.blocks[blockNum] == nil {
+		return
+	}
+	delete(tx.blocks, blockNum)
+	for i, held := range tx.order {
+		if held == blockNum {
+			tx.order = append(tx.order[:i], tx.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// begin starts a transaction, every begin needs a commit. They nest, only the outermost commit stores anything
+func (fsys *FileSys) begin() {
+	if fsys.tx == nil {
+		fsys.tx = &transaction{blocks: map[int][]byte{}, freed: map[int]bool{}}
+	}
+	fsys.tx.depth++
+}
+
+// commit ends what begin started, operations call it deferred with their own error:
+//
+//	fsys.begin()
+//	defer func() { err = fsys.commit(err) }()
+//
+// Whatever the operation wrote is stored even if it failed partway, the operations already tidy
+// up after themselves when something goes wrong. err comes back unchanged unless it was nil and
+// storing the transaction failed, a transaction too big for the journal fails whatever err was
+func (fsys *FileSys) commit(err error) error {
+	tx := fsys.tx
+	tx.depth--
+	if tx.depth > 0 {
+		return err
+	}
+	fsys.tx = nil
+	commitErr := fsys.writeTransaction(tx)
+	if errors.Is(commitErr, ErrJournalFull) {
+		//none of it got stored, so the cached root folder and free block bitmap may be ahead of the disk now
+		fsys.forgetFreeBlocks()
+		if rootErr := fsys.reloadRootFolder(); rootErr != nil {
+			return rootErr
+		}
+		return commitErr
+	}
+	if err == nil {
+		err = commitErr
+	}
+	return err
+}
+
+// reloadRootFolder reads the cached copy of the root folder back in from the disk
+func (fsys *FileSys) reloadRootFolder() error {
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return err
+	}
+	fsys.RootFolder, err = fsys.getInodeFromDisk(sblock.RootDirInode)
+	return err
+}
+
+// checkpoint is for operations that can outgrow the journal. Once the transaction has filled all
+// but JOURNAL_SLACK blocks of it, save is called to write out whatever the operation still keeps
+// in memory (save can be nil), and everything so far is committed. The operation carries on in
+// the same transaction, which starts out empty again. Only call it where the disk would make sense
+// if the operation stopped right there, and where the same is true for any operation around it
+func (fsys *FileSys) checkpoint(save func() error) error {
+	tx := fsys.tx
+	if tx == nil {
+		return nil
+	}
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return err
+	}
+	capacity := fsys.journalCapacity(sblock)
+	if capacity == 0 || len(tx.order) < capacity-JOURNAL_SLACK {
+		return nil
+	}
+	if save != nil {
+		if err := save(); err != nil {
+			return err
+		}
+	}
+	if err := fsys.writeTransaction(tx); err != nil {
+		return err
+	}
+	tx.blocks = map[int][]byte{}
+	tx.order = nil
+	tx.freed = map[int]bool{} //they are free on the disk now, so file contents can go straight to them
+	return nil
+}
+
+// journalHeaderCapacity is how many block numbers fit in a journal header
+func journalHeaderCapacity(blockSize int) int {
+	return (blockSize - JOURNAL_HEADER_SIZE) / 4
+}
+
+// journalCapacity is how many blocks one transaction can log, 0 if the disk has no journal
+func (fsys *FileSys) journalCapacity(sblock SuperBlock) int {
+	if sblock.JournalBlocks == 0 {
+		return 0
+	}
+	return min(sblock.JournalBlocks-1, journalHeaderCapacity(sblock.BlockSize))
+}
+
+// writeTransaction stores everything the transaction held, going through the journal
+func (fsys *FileSys) writeTransaction(tx *transaction) error {
+	if len(tx.order) == 0 {
+		return nil
+	}
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return err
+	}
+	capacity := fsys.journalCapacity(sblock)
+	if capacity == 0 {
+		//a disk from before there was a journal gets written straight home like everything was before
+		return fsys.writeHome(tx.order, tx.blocks)
+	}
+	if len(tx.order) > capacity {
+		return fmt.Errorf("%w: the operation changes %d blocks, the journal holds %d", ErrJournalFull, len(tx.order), capacity)
+	}
+
+	checksum := crc32.NewIEEE()
+	for i, blockNum := range tx.order {
+		checksum.Write(tx.blocks[blockNum])
+		if err := fsys.writeBlockNow(sblock.JournalStart+1+i, tx.blocks[blockNum]); err != nil {
+			return err
+		}
+	}
+	//the file contents this operation wrote have to be stored before the header, or a replay
+	//could leave inodes pointing at blocks that never got their data
+	if err := fsys.Flush(); err != nil {
+		return err
+	}
+	header := JournalHeader{Blocks: tx.order, Checksum: checksum.Sum32()}
+	headerBytes, err := header.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := fsys.writeBlockNow(sblock.JournalStart, headerBytes); err != nil {
+		return err
+	}
+	//from here on the operation has happened, even if we crash before it is all home
+	if err := fsys.Flush(); err != nil {
+		return err
+	}
+	if err := fsys.writeHome(tx.order, tx.blocks); err != nil {
+		return err
+	}
+	if err := fsys.Flush(); err != nil {
+		return err
+	}
+	return fsys.clearJournal(sblock)
+}
+
+// writeHome writes each block where it belongs
+func (fsys *FileSys) writeHome(order []int, blocks map[int][]byte) error {
+	for _, blockNum := range order {
+		if err := fsys.writeBlockNow(blockNum, blocks[blockNum]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clearJournal empties the journal once everything in it is home. It has to be stored before
+// anything else is written, or replaying it later could write old blocks over newer file contents
+func (fsys *FileSys) clearJournal(sblock SuperBlock) error {
+	if err := fsys.writeBlockNow(sblock.JournalStart, nil); err != nil {
+		return err
+	}
+	return fsys.Flush()
+}
+
+// replayJournal finishes off the transaction left in the journal, if there is one. A header whose
+// checksum doesn't match is from a commit that was cut short before it counted, so it's thrown away
+func (fsys *FileSys) replayJournal() error {
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return err
+	}
+	if sblock.JournalBlocks == 0 {
+		return nil
+	}
+	headerBytes, err := fsys.readBlock(sblock.JournalStart)
+	if err != nil {
+		return err
+	}
+	var header JournalHeader
+	if err := header.UnmarshalBinary(headerBytes); err != nil {
+		return err
+	}
+	if len(header.Blocks) == 0 {
+		return nil
+	}
+	if len(header.Blocks) > fsys.journalCapacity(sblock) {
+		return fmt.Errorf("%w: journal header lists %d blocks, the journal only holds %d", ErrCorrupt, len(header.Blocks), fsys.journalCapacity(sblock))
+	}
+
+	blocks := map[int][]byte{}
+	checksum := crc32.NewIEEE()
+	for i, blockNum := range header.Blocks {
+		if blocks[blockNum], err = fsys.readBlock(sblock.JournalStart + 1 + i); err != nil {
+			return err
+		}
+		checksum.Write(blocks[blockNum])
+	}
+	if checksum.Sum32() == header.Checksum {
+		for _, blockNum := range header.Blocks {
+			if blockNum <= 0 || blockNum >= sblock.TotalBlocks || (blockNum >= sblock.JournalStart && blockNum < sblock.DataBlockStart) {
+				return fmt.Errorf("%w: journal wants to write block %d", ErrCorrupt, blockNum)
+			}
+		}
+		if err := fsys.writeHome(header.Blocks, blocks); err != nil {
+			return err
+		}
+		fsys.forgetFreeBlocks()
+		if err := fsys.Flush(); err != nil {
+			return err
+		}
+	}
+	return fsys.clearJournal(sblock)
+}
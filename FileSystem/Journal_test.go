@@ -0,0 +1,140 @@
+package FileSystem
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+var errCrash = errors.New("crashed")
+
+// crashDevice is a MemoryDevice that stops taking writes after writesLeft of them, like a disk
+// whose machine lost power
+type crashDevice struct {
+	*MemoryDevice
+	writesLeft int
+}
+
+func (dev *crashDevice) WriteBlock(blockNum int, buf []byte) error {
+	if dev.writesLeft <= 0 {
+		return errCrash
+	}
+	dev.writesLeft--
+	return dev.MemoryDevice.WriteBlock(blockNum, buf)
+}
+
+// copyDevice makes a copy of the disk in dev that crashes after writesLeft writes
+func copyDevice(dev *MemoryDevice, writesLeft int) *crashDevice {
+	data := append([]byte(nil), dev.data...)
+	return &crashDevice{&MemoryDevice{data: data, blockSize: dev.blockSize}, writesLeft}
+}
+
+// crashEverywhere runs operation on a copy of the disk once for every write it makes, crashing
+// at that write. After each crash the journal is replayed like Mount does and the disk has to
+// come out consistent. step skips crash points to keep long operations quick
+func crashEverywhere(t *testing.T, fsys *FileSys, step int, operation func(fsys *FileSys) error) {
+	t.Helper()
+	dev := fsys.dev.(*MemoryDevice)
+	counter := copyDevice(dev, 1<<30)
+	if err := operation(New(counter)); err != nil {
+		t.Fatalf("without a crash: %v", err)
+	}
+	writes := 1<<30 - counter.writesLeft
+	for crashAt := 0; crashAt < writes; crashAt += step {
+		crashing := copyDevice(dev, crashAt)
+		if err := operation(New(crashing)); !errors.Is(err, errCrash) {
+			t.Fatalf("crash at write %d of %d: got %v", crashAt, writes, err)
+		}
+		remounted := New(crashing.MemoryDevice)
+		if err := remounted.replayJournal(); err != nil {
+			t.Fatalf("replay after a crash at write %d of %d: %v", crashAt, writes, err)
+		}
+		report, err := remounted.Check(false)
+		if err != nil {
+			t.Fatalf("check after a crash at write %d of %d: %v", crashAt, writes, err)
+		}
+		if !report.Clean() {
+			t.Fatalf("crash at write %d of %d left %v", crashAt, writes, report.Problems)
+		}
+	}
+}
+
+func TestJournalCrashDuringRemoveAll(t *testing.T) {
+	for _, format := range []int{INODE_BLOCKS, INODE_EXTENTS} {
+		//far more files than one commit of a 64 block journal can remove
+		fsys := newTestDisk(t, 2000, FormatOptions{InodeFormat: format, NumInodes: 512, JournalBlocks: 64})
+		if _, _, err := fsys.Mkdir("/a"); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 400; i++ {
+			createFile(t, fsys, fmt.Sprint("/a/", i), 10)
+		}
+		crashEverywhere(t, fsys, 1, func(fsys *FileSys) error {
+			return fsys.RemoveAll("/a")
+		})
+	}
+}
+
+func TestJournalCrashDuringBigWrite(t *testing.T) {
+	for _, format := range []int{INODE_BLOCKS, INODE_EXTENTS} {
+		//with small blocks a big write needs more indirect blocks than the smallest journal holds
+		fsys := New(NewMemoryDevice(4000, MIN_BLOCK_SIZE))
+		if err := fsys.Format(FormatOptions{InodeFormat: format, JournalBlocks: MIN_JOURNAL_BLOCKS}); err != nil {
+			t.Fatal(err)
+		}
+		createFile(t, fsys, "/f", 0)
+		crashEverywhere(t, fsys, 23, func(fsys *FileSys) error {
+			parent, _, name, err := fsys.lookupParent("/f")
+			if err != nil {
+				return err
+			}
+			file, err := fsys.Open(WRITE, name, parent)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			_, err = file.Write(make([]byte, 3000*MIN_BLOCK_SIZE))
+			return err
+		})
+	}
+}
+
+func TestJournalCrashDuringBigRemove(t *testing.T) {
+	for _, format := range []int{INODE_BLOCKS, INODE_EXTENTS} {
+		//the file's blocks are listed in more bitmap blocks than the smallest journal holds
+		fsys := New(NewMemoryDevice(140000, MIN_BLOCK_SIZE))
+		if err := fsys.Format(FormatOptions{InodeFormat: format, JournalBlocks: MIN_JOURNAL_BLOCKS}); err != nil {
+			t.Fatal(err)
+		}
+		before := countFreeBlocks(t, fsys)
+		inodeNum := createFile(t, fsys, "/f", 130000*MIN_BLOCK_SIZE)
+		if err := fsys.Truncate(inodeNum, 0); err != nil {
+			t.Fatalf("truncate: %v", err)
+		}
+		createFile(t, fsys, "/f", 130000*MIN_BLOCK_SIZE)
+		crashEverywhere(t, fsys, 97, func(fsys *FileSys) error {
+			return fsys.Remove("/f")
+		})
+		if err := fsys.Remove("/f"); err != nil {
+			t.Fatal(err)
+		}
+		if after := countFreeBlocks(t, fsys); after != before {
+			t.Fatalf("%d blocks free after the remove, want %d", after, before)
+		}
+	}
+}
+
+func TestDefaultJournalFitsHeader(t *testing.T) {
+	for _, blockSize := range []int{MIN_BLOCK_SIZE, 1024, 4096} {
+		sblock, err := FormatOptions{BlockSize: blockSize}.layout()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if listed := journalHeaderCapacity(blockSize); sblock.JournalBlocks-1 > listed {
+			t.Fatalf("%d byte blocks get a journal of %d blocks, the header only lists %d", blockSize, sblock.JournalBlocks, listed)
+		}
+		if blockSize == 1024 && sblock.JournalBlocks-1 != journalHeaderCapacity(blockSize) {
+			t.Fatalf("the default journal of %d blocks doesn't use all of its header", sblock.JournalBlocks)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package FileSystem
+
+import (
+	"fmt"
+	"math"
+)
+
+const MAX_LINKS = math.MaxUint16 //LinkCount is stored in 2 bytes
+
+// Link gives the file at existingPath another name, newPath. Both names are the same file, writing
+// through one shows up through the other, and it is only freed once every name is removed. Like
+// on a real disk folders can't be linked, they have to stay a tree
+func (fsys *FileSys) Link(existingPath string, newPath string) (err error) {
+	fsys.begin()
+	defer func() { err = fsys.commit(err) }()
+
+	file, fileNum, err := fsys.Lookup(existingPath)
+	if err != nil {
+		return err
+	}
+	if file.IsDirectory {
+		return fmt.Errorf("link %s: %w", existingPath, ErrIsDir)
+	}
+	if file.LinkCount >= MAX_LINKS {
+		return fmt.Errorf("link %s: %w: it already has %d names", existingPath, ErrInvalid, file.LinkCount)
+	}
+	parent, _, name, err := fsys.lookupParent(newPath)
+	if err != nil {
+		return err
+	}
+	newEntry, err := newDirectoryEntry(name, fileNum)
+	if err != nil {
+		return err
+	}
+	_, exists, err := fsys.findDirectoryEntry(parent, name)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("link %s: %w", newPath, ErrExist)
+	}
+	if err := fsys.addDirectoryEntry(parent, newEntry); err != nil {
+		return err
+	}
+	file.LinkCount++
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return err
+	}
+	return fsys.writeInodeToDisk(&file, fileNum, sblock)
+}
@@ -0,0 +1,84 @@
+package FileSystem
+
+import (
+	"errors"
+	"testing"
+)
+
+// linkCount reads the link count of inode inodeNum, 0 if it has been freed
+func linkCount(t *testing.T, fsys *FileSys, inodeNum int) int {
+	t.Helper()
+	inode, err := fsys.getInodeFromDisk(inodeNum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !inode.IsValid {
+		return 0
+	}
+	return inode.LinkCount
+}
+
+func TestLinkKeepsFileUntilLastName(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	before := countFreeBlocks(t, fsys)
+	inodeNum := createFile(t, fsys, "/f", 5000)
+	if _, _, err := fsys.Mkdir("/d"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Link("/f", "/d/g"); err != nil {
+		t.Fatal(err)
+	}
+	if count := linkCount(t, fsys, inodeNum); count != 2 {
+		t.Fatalf("link count is %d with two names, want 2", count)
+	}
+	checkClean(t, fsys)
+
+	if err := fsys.Remove("/f"); err != nil {
+		t.Fatal(err)
+	}
+	if count := linkCount(t, fsys, inodeNum); count != 1 {
+		t.Fatalf("link count is %d after removing a name, want 1", count)
+	}
+	if _, _, err := fsys.Lookup("/d/g"); err != nil {
+		t.Fatalf("the other name went too: %v", err)
+	}
+	checkClean(t, fsys)
+
+	if err := fsys.RemoveAll("/d"); err != nil {
+		t.Fatal(err)
+	}
+	if count := linkCount(t, fsys, inodeNum); count != 0 {
+		t.Fatalf("the file is still there with %d links after removing every name", count)
+	}
+	if after := countFreeBlocks(t, fsys); after != before {
+		t.Fatalf("%d blocks free after removing every name, want %d", after, before)
+	}
+	checkClean(t, fsys)
+}
+
+func TestLinkRefusesFolders(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	if _, _, err := fsys.Mkdir("/d"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Link("/d", "/e"); !errors.Is(err, ErrIsDir) {
+		t.Fatalf("linking a folder: got %v, want ErrIsDir", err)
+	}
+	checkClean(t, fsys)
+}
+
+func TestRenameOverLinkedFile(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	createFile(t, fsys, "/f", 10)
+	target := createFile(t, fsys, "/x", 10)
+	if err := fsys.Link("/x", "/y"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Rename("/f", "/x"); err != nil {
+		t.Fatal(err)
+	}
+	if count := linkCount(t, fsys, target); count != 1 {
+		t.Fatalf("the replaced file has %d links, want 1 for /y", count)
+	}
+	checkClean(t, fsys)
+}
@@ -0,0 +1,178 @@
+package FileSystem
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lookup walks path through the folders on disk and returns the inode it ends at and its number.
+// Paths starting with / begin at the root folder, anything else begins at the current folder.
+// . and .. work the same as everywhere else (.. of the root folder is the root folder).
+func (fsys *FileSys) Lookup(path string) (INode, int, error) {
+	start, err := fsys.startOfPath(path)
+	if err != nil {
+		return INode{}, 0, err
+	}
+	return fsys.lookupFrom(start, path)
+}
+
+// startOfPath is the inode number that path is relative to
+func (fsys *FileSys) startOfPath(path string) (int, error) {
+	if strings.HasPrefix(path, "/") || fsys.cwd == 0 {
+		sblock, err := fsys.ReadSuperBlock()
+		if err != nil {
+			return 0, err
+		}
+		return sblock.RootDirInode, nil
+	}
+	return fsys.cwd, nil
+}
+
+// lookupFrom walks path starting from the folder with inode number startNum
+func (fsys *FileSys) lookupFrom(startNum int, path string) (INode, int, error) {
+	currentNum := startNum
+	current, err := fsys.getInodeFromDisk(currentNum)
+	if err != nil {
+		return INode{}, 0, err
+	}
+	for _, part := range strings.Split(path, "/") {
+		if part == "" || part == "." {
+			continue //leading, trailing and doubled slashes
+		}
+		if !current.IsDirectory || !current.IsValid {
+			return INode{}, 0, fmt.Errorf("lookup %s: %w", path, ErrNotDir)
+		}
+		entry, found, err := fsys.findDirectoryEntry(current, part)
+		if err != nil {
+			return INode{}, 0, fmt.Errorf("lookup %s: %w", path, err)
+		}
+		if !found {
+			return INode{}, 0, fmt.Errorf("lookup %s: %w", path, ErrNotFound)
+		}
+		currentNum = entry.Inode
+		if current, err = fsys.getInodeFromDisk(currentNum); err != nil {
+			return INode{}, 0, err
+		}
+		if !current.IsValid {
+			return INode{}, 0, fmt.Errorf("%w: entry %s points at free inode %d", ErrCorrupt, part, currentNum)
+		}
+	}
+	return current, currentNum, nil
+}
+
+// lookupParent finds the folder that would hold the last part of path, and that last part
+func (fsys *FileSys) lookupParent(path string) (parent INode, parentNum int, name string, err error) {
+	trimmed := strings.TrimRight(path, "/")
+	if trimmed == "" {
+		return INode{}, 0, "", fmt.Errorf("%w: %q has no file name", ErrBadName, path)
+	}
+	dirPath, name := "", trimmed
+	if slash := strings.LastIndex(trimmed, "/"); slash >= 0 {
+		dirPath, name = trimmed[:slash+1], trimmed[slash+1:]
+	}
+	start, err := fsys.startOfPath(path)
+	if err != nil {
+		return INode{}, 0, "", err
+	}
+	parent, parentNum, err = fsys.lookupFrom(start, dirPath)
+	if err != nil {
+		return INode{}, 0, "", err
+	}
+	if !parent.IsDirectory {
+		return INode{}, 0, "", fmt.Errorf("lookup %s: %w", dirPath, ErrNotDir)
+	}
+	return parent, parentNum, name, nil
+}
+
+// Chdir makes path the folder that relative paths start from
+func (fsys *FileSys) Chdir(path string) error {
+	dir, dirNum, err := fsys.Lookup(path)
+	if err != nil {
+		return err
+	}
+	if !dir.IsDirectory {
+		return fmt.Errorf("chdir %s: %w", path, ErrNotDir)
+	}
+	fsys.cwd = dirNum
+	return nil
+}
+
+// Getwd works out the absolute path of the current folder by following .. entries up to the root
+func (fsys *FileSys) Getwd() (string, error) {
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return "", err
+	}
+	if fsys.cwd == 0 {
+		return "/", nil
+	}
+	return fsys.pathOf(fsys.cwd, sblock)
+}
+
+// leaveRemovedCwd moves the current folder back to the root if it was just removed
+func (fsys *FileSys) leaveRemovedCwd() {
+	if fsys.cwd == 0 {
+		return
+	}
+	if cwd, err := fsys.getInodeFromDisk(fsys.cwd); err != nil || !cwd.IsValid {
+		fsys.cwd = 0
+	}
+}
+
+// errDotDotLoop is what walking up the .. entries gives if it goes round more times than there
+// are inodes, so it can't ever get to the root folder
+var errDotDotLoop = fmt.Errorf("%w: .. entries loop without reaching the root folder", ErrCorrupt)
+
+// parentOf follows the .. entry of the folder with inode number dirNum
+func (fsys *FileSys) parentOf(dirNum int) (int, error) {
+	dir, err := fsys.getInodeFromDisk(dirNum)
+	if err != nil {
+		return 0, err
+	}
+	if !dir.IsValid {
+		return 0, fmt.Errorf("folder inode %d: %w", dirNum, ErrNotFound)
+	}
+	dotdot, found, err := fsys.findDirectoryEntry(dir, "..")
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, fmt.Errorf("%w: folder inode %d has no .. entry", ErrCorrupt, dirNum)
+	}
+	return dotdot.Inode, nil
+}
+
+// pathOf builds the absolute path of the folder with inode number dirNum
+func (fsys *FileSys) pathOf(dirNum int, sblock SuperBlock) (string, error) {
+	names := []string{}
+	for steps := 0; dirNum != sblock.RootDirInode; steps++ {
+		if steps >= sblock.NumInodes {
+			return "", errDotDotLoop
+		}
+		parentNum, err := fsys.parentOf(dirNum)
+		if err != nil {
+			return "", err
+		}
+		parent, err := fsys.getInodeFromDisk(parentNum)
+		if err != nil {
+			return "", err
+		}
+		entries, err := fsys.readDirectoryEntries(parent)
+		if err != nil {
+			return "", err
+		}
+		name := ""
+		for _, entry := range entries {
+			if entryName(entry) != "." && entryName(entry) != ".." && entry.Inode == dirNum {
+				name = entryName(entry)
+				break
+			}
+		}
+		if name == "" {
+			return "", fmt.Errorf("folder inode %d is not in its parent: %w", dirNum, ErrNotFound)
+		}
+		names = append([]string{name}, names...)
+		dirNum = parentNum
+	}
+	return "/" + strings.Join(names, "/"), nil
+}
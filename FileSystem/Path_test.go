@@ -0,0 +1,86 @@
+package FileSystem
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	fileNum := createFile(t, fsys, "f", 10)
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := sblock.RootDirInode
+	tests := []struct {
+		path    string
+		wantNum int
+		wantErr error
+	}{
+		{"/", root, nil},
+		{".", root, nil},
+		{"..", root, nil},
+		{"/..", root, nil},
+		{"/f", fileNum, nil},
+		{"f", fileNum, nil},
+		{"//./f/", fileNum, nil},
+		{"/../f", fileNum, nil},
+		{"/missing", 0, ErrNotFound},
+		{"/f/x", 0, ErrNotDir},
+	}
+	for _, test := range tests {
+		_, inodeNum, err := fsys.Lookup(test.path)
+		if !errors.Is(err, test.wantErr) {
+			t.Errorf("lookup %s: got %v, want %v", test.path, err, test.wantErr)
+			continue
+		}
+		if inodeNum != test.wantNum {
+			t.Errorf("lookup %s: got inode %d, want %d", test.path, inodeNum, test.wantNum)
+		}
+	}
+}
+
+func TestChdirAndGetwd(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	if _, _, err := fsys.Mkdir("/a"); err != nil {
+		t.Fatal(err)
+	}
+	_, bNum, err := fsys.Mkdir("/a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	createFile(t, fsys, "/a/f", 10)
+	if err := fsys.Chdir("/a/b"); err != nil {
+		t.Fatal(err)
+	}
+	if wd, err := fsys.Getwd(); err != nil || wd != "/a/b" {
+		t.Fatalf("getwd after cd /a/b: got %q, %v", wd, err)
+	}
+	if _, inodeNum, err := fsys.Lookup("."); err != nil || inodeNum != bNum {
+		t.Fatalf("lookup . in /a/b: got inode %d, %v, want %d", inodeNum, err, bNum)
+	}
+	//relative paths start from the current folder, absolute ones don't
+	createFile(t, fsys, "g", 10)
+	if _, _, err := fsys.Lookup("/a/b/g"); err != nil {
+		t.Fatalf("a file made with a relative path isn't in the current folder: %v", err)
+	}
+	if _, _, err := fsys.Lookup("../f"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Chdir("../f"); !errors.Is(err, ErrNotDir) {
+		t.Fatalf("cd into a file: got %v, want ErrNotDir", err)
+	}
+	if err := fsys.Chdir(".."); err != nil {
+		t.Fatal(err)
+	}
+	if wd, err := fsys.Getwd(); err != nil || wd != "/a" {
+		t.Fatalf("getwd after cd ..: got %q, %v", wd, err)
+	}
+	if err := fsys.Chdir("/"); err != nil {
+		t.Fatal(err)
+	}
+	if wd, err := fsys.Getwd(); err != nil || wd != "/" {
+		t.Fatalf("getwd after cd /: got %q, %v", wd, err)
+	}
+}
@@ -0,0 +1,149 @@
+package FileSystem
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Remove deletes the file at path, or just that name for it if it has others (see Link). Folders
+// have to go through Rmdir or RemoveAll
+func (fsys *FileSys) Remove(path string) (err error) {
+	fsys.begin()
+	defer func() { err = fsys.commit(err) }()
+
+	parent, name, target, err := fsys.lookupForRemove(path)
+	if err != nil {
+		return err
+	}
+	if target.IsDirectory {
+		return fmt.Errorf("remove %s: %w", path, ErrIsDir)
+	}
+	return fsys.unlinkName(parent, name)
+}
+
+// Rmdir deletes the folder at path, but only if there is nothing in it besides . and ..
+func (fsys *FileSys) Rmdir(path string) (err error) {
+	fsys.begin()
+	defer func() { err = fsys.commit(err) }()
+
+	parent, name, target, err := fsys.lookupForRemove(path)
+	if err != nil {
+		return err
+	}
+	if !target.IsDirectory {
+		return fmt.Errorf("rmdir %s: %w", path, ErrNotDir)
+	}
+	empty, err := fsys.isEmptyDirectory(target)
+	if err != nil {
+		return err
+	}
+	if !empty {
+		return fmt.Errorf("rmdir %s: %w", path, ErrNotEmpty)
+	}
+	if err := fsys.unlinkName(parent, name); err != nil {
+		return err
+	}
+	fsys.leaveRemovedCwd()
+	return nil
+}
+
+// RemoveAll deletes path and, if it is a folder, everything underneath it. Like os.RemoveAll a
+// path that doesn't exist isn't an error. A big folder is committed a few entries at a time, so
+// a crash partway leaves some of what was in it behind
+func (fsys *FileSys) RemoveAll(path string) (err error) {
+	fsys.begin()
+	defer func() { err = fsys.commit(err) }()
+
+	parent, name, target, err := fsys.lookupForRemove(path)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if target.IsDirectory {
+		if err := fsys.removeChildren(target); err != nil {
+			return err
+		}
+	}
+	if err := fsys.unlinkName(parent, name); err != nil {
+		return err
+	}
+	fsys.leaveRemovedCwd()
+	return nil
+}
+
+// removeChildren unlinks everything inside the folder, emptying folders below it first
+func (fsys *FileSys) removeChildren(dir INode) error {
+	entries, err := fsys.readDirectoryEntries(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if name := entryName(entry); name == "." || name == ".." {
+			continue
+		}
+		child, err := fsys.getInodeFromDisk(entry.Inode)
+		if err != nil {
+			return err
+		}
+		if child.IsDirectory {
+			if err := fsys.removeChildren(child); err != nil {
+				return err
+			}
+		}
+		if err := fsys.unlinkName(dir, entryName(entry)); err != nil {
+			return err
+		}
+		//every child that's gone leaves the disk making sense, so a big folder goes a few at a time
+		if err := fsys.checkpoint(nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookupForRemove finds what path names along with the folder it is in and its name there. The
+// root folder and paths ending in . or .. can't be removed
+func (fsys *FileSys) lookupForRemove(path string) (parent INode, name string, target INode, err error) {
+	parent, _, name, err = fsys.lookupParent(path)
+	if err != nil {
+		return INode{}, "", INode{}, err
+	}
+	if name == "." || name == ".." {
+		return INode{}, "", INode{}, fmt.Errorf("remove %s: %w", path, ErrInvalid)
+	}
+	entry, found, err := fsys.findDirectoryEntry(parent, name)
+	if err != nil {
+		return INode{}, "", INode{}, err
+	}
+	if !found {
+		return INode{}, "", INode{}, fmt.Errorf("remove %s: %w", path, ErrNotFound)
+	}
+	target, err = fsys.getInodeFromDisk(entry.Inode)
+	if err != nil {
+		return INode{}, "", INode{}, err
+	}
+	return parent, name, target, nil
+}
+
+// unlinkName removes the entry called name from the folder. Unlink goes by inode number, which
+// could pick the wrong entry when a file has two names in the same folder
+func (fsys *FileSys) unlinkName(dir INode, name string) error {
+	entry, found, err := fsys.findDirectoryEntry(dir, name)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("remove %s: %w", name, ErrNotFound)
+	}
+	if err := fsys.emptyLastName(entry.Inode); err != nil {
+		return err
+	}
+	if _, err := fsys.removeDirectoryEntry(dir, func(entry DirectoryEntry) bool {
+		return entryName(entry) == name
+	}); err != nil {
+		return fmt.Errorf("remove %s: %w", name, err)
+	}
+	return fsys.dropLink(entry.Inode)
+}
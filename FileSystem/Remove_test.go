@@ -0,0 +1,95 @@
+package FileSystem
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRemove(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	fileNum := createFile(t, fsys, "/f", 3000)
+	if _, _, err := fsys.Mkdir("/d"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Remove("/f"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := fsys.Lookup("/f"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("lookup a removed file: got %v, want ErrNotFound", err)
+	}
+	if inode, err := fsys.getInodeFromDisk(fileNum); err != nil || inode.IsValid {
+		t.Fatalf("the removed file's inode wasn't freed: %v", err)
+	}
+	tests := []struct {
+		path string
+		want error
+	}{
+		{"/f", ErrNotFound},
+		{"/d", ErrIsDir},
+		{"/d/..", ErrInvalid},
+		{"/", ErrBadName},
+	}
+	for _, test := range tests {
+		if err := fsys.Remove(test.path); !errors.Is(err, test.want) {
+			t.Errorf("remove %s: got %v, want %v", test.path, err, test.want)
+		}
+	}
+}
+
+func TestRmdir(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	for _, path := range []string{"/empty", "/full"} {
+		if _, _, err := fsys.Mkdir(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	createFile(t, fsys, "/full/f", 10)
+	createFile(t, fsys, "/f", 10)
+	tests := []struct {
+		path string
+		want error
+	}{
+		{"/full", ErrNotEmpty},
+		{"/f", ErrNotDir},
+		{"/missing", ErrNotFound},
+		{"/empty", nil},
+	}
+	for _, test := range tests {
+		if err := fsys.Rmdir(test.path); !errors.Is(err, test.want) {
+			t.Errorf("rmdir %s: got %v, want %v", test.path, err, test.want)
+		}
+	}
+	if _, _, err := fsys.Lookup("/empty"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("lookup a removed folder: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestRemoveAll(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	for _, path := range []string{"/a", "/a/b", "/a/b/c", "/keep"} {
+		if _, _, err := fsys.Mkdir(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	createFile(t, fsys, "/a/f", 5000)
+	createFile(t, fsys, "/a/b/c/g", 10)
+	if err := fsys.Chdir("/a/b/c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.RemoveAll("/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := fsys.Lookup("/a"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("lookup a removed folder: got %v, want ErrNotFound", err)
+	}
+	if _, _, err := fsys.Lookup("/keep"); err != nil {
+		t.Fatalf("a folder next to the removed one went too: %v", err)
+	}
+	//the current folder was inside, so relative paths start from the root again
+	if wd, err := fsys.Getwd(); err != nil || wd != "/" {
+		t.Fatalf("getwd after removing the current folder: got %q, %v, want /", wd, err)
+	}
+	if err := fsys.RemoveAll("/a"); err != nil {
+		t.Fatalf("removing a path that isn't there: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package FileSystem
+
+import "fmt"
+
+// Rename moves the file or folder at oldPath to newPath by moving its directory entry, the data
+// stays where it is. If newPath already exists it is replaced, as long as it is the same kind of
+// thing as oldPath (and an empty folder, if it is a folder). A folder can't be moved inside itself.
+func (fsys *FileSys) Rename(oldPath string, newPath string) (err error) {
+	fsys.begin()
+	defer func() { err = fsys.commit(err) }()
+
+	oldParent, oldParentNum, oldName, err := fsys.lookupParent(oldPath)
+	if err != nil {
+		return err
+	}
+	newParent, newParentNum, newName, err := fsys.lookupParent(newPath)
+	if err != nil {
+		return err
+	}
+	if oldName == "." || oldName == ".." {
+		return fmt.Errorf("rename %s: %w", oldPath, ErrInvalid)
+	}
+	newEntry, err := newDirectoryEntry(newName, 0)
+	if err != nil {
+		return err
+	}
+
+	oldEntry, found, err := fsys.findDirectoryEntry(oldParent, oldName)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("rename %s: %w", oldPath, ErrNotFound)
+	}
+	movingNum := oldEntry.Inode
+	moving, err := fsys.getInodeFromDisk(movingNum)
+	if err != nil {
+		return err
+	}
+	newEntry.Inode = movingNum
+
+	if moving.IsDirectory {
+		inside, err := fsys.isInside(newParentNum, movingNum)
+		if err != nil {
+			return err
+		}
+		if inside {
+			return fmt.Errorf("rename %s to %s: can't move a folder inside itself: %w", oldPath, newPath, ErrInvalid)
+		}
+	}
+
+	targetEntry, targetExists, err := fsys.findDirectoryEntry(newParent, newName)
+	if err != nil {
+		return err
+	}
+	if targetExists {
+		if targetEntry.Inode == movingNum {
+			return nil //both names already mean the same file
+		}
+		target, err := fsys.getInodeFromDisk(targetEntry.Inode)
+		if err != nil {
+			return err
+		}
+		if err := fsys.checkReplaceable(moving, target, newPath); err != nil {
+			return err
+		}
+		if err := fsys.emptyLastName(targetEntry.Inode); err != nil {
+			return err
+		}
+		//point the existing entry at the file being moved, so newPath never stops existing
+		if err := fsys.setDirectoryEntry(newParent, newName, movingNum); err != nil {
+			return err
+		}
+	} else if err := fsys.addDirectoryEntry(newParent, newEntry); err != nil {
+		return err
+	}
+
+	if _, err := fsys.removeDirectoryEntry(oldParent, func(entry DirectoryEntry) bool {
+		return entryName(entry) == oldName && entry.Inode == movingNum
+	}); err != nil {
+		return err
+	}
+	if moving.IsDirectory && oldParentNum != newParentNum {
+		if err := fsys.setDirectoryEntry(moving, "..", newParentNum); err != nil {
+			return err
+		}
+	}
+	if targetExists {
+		if err := fsys.dropLink(targetEntry.Inode); err != nil { //it may still have other names
+			return err
+		}
+		fsys.leaveRemovedCwd() //the folder it replaced may have been the current one
+	}
+	return nil
+}
+
+// checkReplaceable makes sure moving may take the place of target
+func (fsys *FileSys) checkReplaceable(moving INode, target INode, targetPath string) error {
+	if moving.IsDirectory && !target.IsDirectory {
+		return fmt.Errorf("rename onto %s: %w", targetPath, ErrNotDir)
+	}
+	if !moving.IsDirectory && target.IsDirectory {
+		return fmt.Errorf("rename onto %s: %w", targetPath, ErrIsDir)
+	}
+	if target.IsDirectory {
+		empty, err := fsys.isEmptyDirectory(target)
+		if err != nil {
+			return err
+		}
+		if !empty {
+			return fmt.Errorf("rename onto %s: %w", targetPath, ErrNotEmpty)
+		}
+	}
+	return nil
+}
+
+// isInside reports whether the folder dirNum is ancestorNum or somewhere underneath it
+func (fsys *FileSys) isInside(dirNum int, ancestorNum int) (bool, error) {
+	sblock, err := fsys.ReadSuperBlock()
+	if err != nil {
+		return false, err
+	}
+	for steps := 0; ; steps++ {
+		if dirNum == ancestorNum {
+			return true, nil
+		}
+		if dirNum == sblock.RootDirInode {
+			return false, nil
+		}
+		if steps >= sblock.NumInodes {
+			return false, errDotDotLoop
+		}
+		if dirNum, err = fsys.parentOf(dirNum); err != nil {
+			return false, err
+		}
+	}
+}
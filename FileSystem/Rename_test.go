@@ -0,0 +1,112 @@
+package FileSystem
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestRenameFile(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	fileNum := createFile(t, fsys, "/f", 3000)
+	if _, _, err := fsys.Mkdir("/d"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Rename("/f", "/d/g"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := fsys.Lookup("/f"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("the old name is still there: %v", err)
+	}
+	if _, inodeNum, err := fsys.Lookup("/d/g"); err != nil || inodeNum != fileNum {
+		t.Fatalf("lookup the new name: got inode %d, %v, want %d", inodeNum, err, fileNum)
+	}
+	file := openFile(t, fsys, "/d/g")
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(content) != 3000 || content[2999] != byte(2999%256) {
+		t.Fatalf("the contents changed when the file moved")
+	}
+}
+
+func TestRenameReplaces(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	fileNum := createFile(t, fsys, "/f", 10)
+	targetNum := createFile(t, fsys, "/g", 10)
+	if err := fsys.Rename("/f", "/g"); err != nil {
+		t.Fatal(err)
+	}
+	if _, inodeNum, err := fsys.Lookup("/g"); err != nil || inodeNum != fileNum {
+		t.Fatalf("lookup the replaced name: got inode %d, %v, want %d", inodeNum, err, fileNum)
+	}
+	if target, err := fsys.getInodeFromDisk(targetNum); err != nil || target.IsValid {
+		t.Fatalf("the replaced file wasn't freed: %v", err)
+	}
+	//renaming a name onto itself leaves it alone
+	if err := fsys.Rename("/g", "/g"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := fsys.Lookup("/g"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenameFolder(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	for _, path := range []string{"/a", "/a/sub", "/b", "/full", "/full/x"} {
+		if _, _, err := fsys.Mkdir(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	createFile(t, fsys, "/f", 10)
+	tests := []struct {
+		from, to string
+		want     error
+	}{
+		{"/a", "/a/sub/a", ErrInvalid},
+		{"/a", "/a/x", ErrInvalid},
+		{"/a", "/f", ErrNotDir},
+		{"/f", "/b", ErrIsDir},
+		{"/a", "/full", ErrNotEmpty},
+		{"/missing", "/c", ErrNotFound},
+		{"/a", "/b/a", nil},
+	}
+	for _, test := range tests {
+		if err := fsys.Rename(test.from, test.to); !errors.Is(err, test.want) {
+			t.Errorf("rename %s to %s: got %v, want %v", test.from, test.to, err, test.want)
+		}
+	}
+	//the folder's .. has to follow it to its new parent
+	_, bNum, err := fsys.Lookup("/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, inodeNum, err := fsys.Lookup("/b/a/.."); err != nil || inodeNum != bNum {
+		t.Fatalf("lookup /b/a/..: got inode %d, %v, want %d", inodeNum, err, bNum)
+	}
+	if _, _, err := fsys.Lookup("/b/a/sub"); err != nil {
+		t.Fatalf("the folder's contents didn't move with it: %v", err)
+	}
+}
+
+func TestRenameOverCwd(t *testing.T) {
+	fsys := newTestDisk(t, 3000, FormatOptions{})
+	for _, path := range []string{"/a", "/e"} {
+		if _, _, err := fsys.Mkdir(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fsys.Chdir("/e"); err != nil {
+		t.Fatal(err)
+	}
+	//the empty folder the current folder was gets replaced, and freed
+	if err := fsys.Rename("/a", "/e"); err != nil {
+		t.Fatal(err)
+	}
+	if wd, err := fsys.Getwd(); err != nil || wd != "/" {
+		t.Fatalf("getwd after the current folder was replaced: got %q, %v, want /", wd, err)
+	}
+}